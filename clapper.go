@@ -28,10 +28,15 @@ package clapper
 // TODO descriptions for help
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -46,15 +51,57 @@ func (e UnknownCommand) Error() string {
 	return fmt.Sprintf("unknown command %s found in the arguments", e.Name)
 }
 
+// BadArgument represents an error when the value of an argument or a flag is
+// missing or invalid. For a value violating a constraint (see `Arg.WithRange`,
+// `Arg.WithLength`, `Arg.WithPattern` and `Arg.WithCount`), `Constraint` names
+// the constraint and `Position` is the position of the offending value in the
+// values passed to `Parse` (or -1 if no value was provided). For a value
+// rejected by a validator (see `Arg.WithValidator`), `Err` is the error of the
+// validator and `Position` is the position of its last occurrence.
 type BadArgument struct {
-	Arg     *Arg
-	Message string
+	Arg        *Arg
+	Message    string
+	Constraint string
+	Position   int
+	Err        error
 }
 
 func (e BadArgument) Error() string {
 	return fmt.Sprintf("%s %s", e.Arg.Name, e.Message)
 }
 
+func (e BadArgument) Unwrap() error {
+	return e.Err
+}
+
+// UnexpectedArgument represents an error when command-line arguments contain
+// more positional values than a command with `StrictArgs` takes.
+type UnexpectedArgument struct {
+	Value    string
+	Position int
+}
+
+func (e UnexpectedArgument) Error() string {
+	return fmt.Sprintf("unexpected argument %s found in the arguments", e.Value)
+}
+
+// BadCommand represents an error of a validator of a command (see `CommandConfig.AddValidator`).
+type BadCommand struct {
+	Command *CommandConfig
+	Err     error
+}
+
+func (e BadCommand) Error() string {
+	if e.Command.Name == "" {
+		return fmt.Sprintf("invalid arguments: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid arguments of command %s: %v", e.Command.Name, e.Err)
+}
+
+func (e BadCommand) Unwrap() error {
+	return e.Err
+}
+
 // UnknownFlag represents an error when command-line arguments contain an unregistered flag.
 type UnknownFlag struct {
 	Name string
@@ -64,14 +111,78 @@ func (e UnknownFlag) Error() string {
 	return fmt.Sprintf("unknown flag %s found in the arguments", e.Name)
 }
 
+// AmbiguousFlag represents an error when an abbreviated long flag matches more than one registered flag.
+type AmbiguousFlag struct {
+	Name    string
+	Matches []string
+}
+
+func (e AmbiguousFlag) Error() string {
+	return fmt.Sprintf("ambiguous flag %s matches %s", e.Name, strings.Join(e.Matches, ", "))
+}
+
+// WrongType represents an error when the value of an argument is accessed as a type other than its own.
+type WrongType struct {
+	Arg  *Arg
+	Type reflect.Type
+}
+
+func (e WrongType) Error() string {
+	if e.Arg.value != nil {
+		return fmt.Sprintf("%s has a value of type %T, not %v", e.Arg.Name, e.Arg.value, e.Type)
+	}
+	return fmt.Sprintf("%s has a default value of type %T, not %v", e.Arg.Name, e.Arg.defaultValue, e.Type)
+}
+
+// MissingValue represents an error when the value of an argument is accessed, but it has neither a value nor a default value.
+type MissingValue struct {
+	Arg *Arg
+}
+
+func (e MissingValue) Error() string {
+	return fmt.Sprintf("%s has no value", e.Arg.Name)
+}
+
 /*---------------------*/
 
+// ErrFrozen is the error returned (or, by `Register` and `AddArg`, recorded
+// for `Registry.Validate`) when a command, argument or flag is registered with
+// a frozen registry.
+var ErrFrozen = errors.New("the registry is frozen")
+
 // Registry holds the configuration of the registered commands.
-type Registry map[string]*CommandConfig
+// Registering commands, arguments and flags and parsing command-line arguments
+// are safe for concurrent use, but the exported fields of the registered
+// `*CommandConfig`, `*Arg` and `*Flag` objects must not be modified while
+// command-line arguments are parsed.
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]*CommandConfig
+	frozen   bool
+
+	// errors of commands which could not be registered (see `Validate`)
+	problems []SchemaError
+
+	// clock of relative time values
+	now func() time.Time
+}
 
 // NewRegistry returns new instance of the "Registry"
-func NewRegistry() Registry {
-	return make(Registry)
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]*CommandConfig),
+		now:      time.Now,
+	}
+}
+
+// SetClock method sets the clock returning the current time, against which
+// relative time values (e.g. `now`, `-2h` or `yesterday`) are evaluated.
+// The default clock is `time.Now`; another one can be set for testing.
+func (registry *Registry) SetClock(now func() time.Time) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.now = now
 }
 
 // Register method registers a command.
@@ -79,13 +190,17 @@ func NewRegistry() Registry {
 // If "name" is an empty string, it is considered as a root command.
 // If a command is already registered, the registered `*CommandConfig` object is returned.
 // If the command is already registered, second return value will be `true`.
-func (registry Registry) Register(name string) (*CommandConfig, bool) {
+// If the registry is frozen, a new command is not registered, and `ErrFrozen`
+// is recorded, so `Validate` returns it.
+func (registry *Registry) Register(name string) (*CommandConfig, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
 
 	// remove all whitespaces
 	commandName := removeWhitespaces(name)
 
 	// check if command is already registered, if found, return existing entry
-	if _commandConfig, ok := registry[commandName]; ok {
+	if _commandConfig, ok := registry.commands[commandName]; ok {
 		return _commandConfig, true
 	}
 
@@ -96,18 +211,77 @@ func (registry Registry) Register(name string) (*CommandConfig, bool) {
 		flagsShort: make(map[string]string),
 		Args:       make(map[string]*Arg),
 		ArgNames:   make([]string, 0),
+		registry:   registry,
+	}
+
+	// a frozen registry records the command as a mistake instead of registering it
+	if registry.frozen {
+		registry.problems = append(registry.problems, SchemaError{Command: commandName, Message: ErrFrozen.Error()})
+		return commandConfig, false
 	}
 
 	// add entry to the registry
-	registry[commandName] = commandConfig
+	registry.commands[commandName] = commandConfig
 
 	return commandConfig, false
 }
 
-// Parse method parses command-line arguments and returns an appropriate "*CommandConfig" object registered in the registry.
+// Command method returns the registered `*CommandConfig` object of a command.
+// If the command is not registered, it returns `nil`.
+func (registry *Registry) Command(name string) *CommandConfig {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return registry.commands[removeWhitespaces(name)]
+}
+
+// Freeze method validates the registered commands (see `Validate`) and locks the registry.
+// Once frozen, registering a command, an argument or a flag fails with `ErrFrozen`.
+// If the registered commands are not valid, the registry is not frozen and the `SchemaErrors` are returned.
+// Freezing a frozen registry returns the `SchemaErrors` of the registrations which failed since.
+func (registry *Registry) Freeze() error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.frozen {
+		return registry.validate()
+	}
+
+	if err := registry.validate(); err != nil {
+		return err
+	}
+
+	registry.frozen = true
+
+	return nil
+}
+
+// Parse method parses command-line arguments of a command registered in the registry and returns their values in a new "*ParseResult" object.
+// The registered "*CommandConfig" objects are not modified, so a registry can parse any number of command-line argument lists.
 // If command is not registered, it return `ErrorUnknownCommand` error.
 // If there is an error parsing a flag, it can return an `ErrorUnknownFlag` or `ErrorUnsupportedFlag` error.
-func (registry Registry) Parse(values []string) (*CommandConfig, error) {
+// Parse is safe for concurrent use. The custom validators (see `Arg.WithValidator`
+// and `CommandConfig.AddValidator`) are called once the registry is unlocked,
+// so they can use it.
+func (registry *Registry) Parse(values []string) (*ParseResult, error) {
+	result, flagNames, err := registry.parse(values)
+	if err != nil {
+		return nil, err
+	}
+
+	// custom validators check the complete result
+	if err := runValidators(result, flagNames); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parse parses command-line arguments (see `Parse`), with the registry locked,
+// and returns their values and the sorted names of the flags.
+func (registry *Registry) parse(values []string) (*ParseResult, []string, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
 
 	// command name
 	var commandName string
@@ -122,38 +296,40 @@ func (registry Registry) Parse(values []string) (*CommandConfig, error) {
 		commandName, valuesToProcess = nextValue(values)
 	}
 
+	// get `CommandConfig` object from the registry (`nil` if not registered)
+	commandConfig, ok := registry.commands[commandName]
+
 	// format command-line argument values
-	valuesToProcess = formatCommandValues(valuesToProcess)
+	tokens := formatCommandValues(valuesToProcess, len(values)-len(valuesToProcess), commandConfig)
 
 	// check for invalid flag structure
-	for _, val := range valuesToProcess {
-		if isFlag(val) && isUnknownFlag(val) {
-			return nil, UnknownFlag{val}
+	for _, tok := range tokens {
+		if isFlag(tok.value) && !tok.literal && isUnknownFlag(tok.value) {
+			return nil, nil, UnknownFlag{tok.value}
 		}
 	}
 
 	// if command is not registered, return `ErrorUnknownCommand` error
-	if _, ok := registry[commandName]; !ok {
-		return nil, UnknownCommand{commandName}
+	if !ok {
+		return nil, nil, UnknownCommand{commandName}
 	}
 
-	// get `CommandConfig` object from the registry
-	commandConfig := registry[commandName]
+	// values are stored in copies of the registered flags and arguments
+	result := newParseResult(commandConfig, registry.now)
+
+	// positional values, in order
+	var positionals []token
 
 	// process all command-line arguments (except command name)
-	for {
+	for len(tokens) > 0 {
 
 		// get current command-line argument value
-		var value string
-		value, valuesToProcess = nextValue(valuesToProcess)
-
-		// if `value` is empty, break the loop
-		if len(value) == 0 {
-			break
-		}
+		tok := tokens[0]
+		tokens = tokens[1:]
+		value := tok.value
 
 		// if the thing is a flag, process as a flag; otherwise, process as an arg
-		if isFlag(value) {
+		if isFlag(value) && !tok.literal {
 
 			// trim `-` characters from the `value`
 			name := strings.TrimLeft(value, "-")
@@ -165,13 +341,13 @@ func (registry Registry) Parse(values []string) (*CommandConfig, error) {
 			// check if flag is short or long
 			if isShortFlag(value) {
 				if _, ok := commandConfig.flagsShort[name]; !ok {
-					return nil, UnknownFlag{value}
+					return nil, nil, UnknownFlag{value}
 				}
 
 				// get long flag name
 				flagName := commandConfig.flagsShort[name]
 
-				flag = commandConfig.Flags[flagName]
+				flag = result.Flags[flagName]
 
 				_, isBool = flag.defaultValue.(bool)
 
@@ -184,116 +360,295 @@ func (registry Registry) Parse(values []string) (*CommandConfig, error) {
 
 				// If it's a long flag, the check for bool (which has no value)
 				var isInv bool
-				if strings.HasPrefix(name, "no-") {
-					name = name[3:]
-					isInv = true
-				}
-				flag = commandConfig.Flags[name]
-				if flag == nil {
-					return nil, UnknownFlag{value}
+				var err error
+				if flag, isInv, err = commandConfig.lookupLongFlag(value); err != nil {
+					return nil, nil, err
 				}
+				flag = result.Flags[flag.Name]
 				_, isBool = flag.defaultValue.(bool)
 				if isBool {
 					flag.value = !isInv
 				}
 				if isInv {
 					if !isBool {
-						return nil, BadArgument{&flag.Arg, "non-bool flag"}
+						return nil, nil, BadArgument{Arg: &flag.Arg, Message: "non-bool flag"}
 					}
 				}
 			}
+			flag.positions = append(flag.positions, tok.pos)
+
+			// position of the value of the flag
+			valuePos := tok.pos
+
 			var err error
-			if !isBool {
-				if nextValue, nextValuesToProcess := nextValue(valuesToProcess); len(nextValue) != 0 && !isFlag(nextValue) {
-					if flag.value, err = convert(nextValue, flag.defaultValue); err != nil {
-						return nil, err
-					}
-					valuesToProcess = nextValuesToProcess
-				} else if len(nextValue) == 0 {
-					return nil, BadArgument{&flag.Arg, "parameter requires an argument, none was provided"}
+			switch {
+			case isBool:
+				// a boolean flag takes no value; an attached one is an argument
+				if tok.attached != "" {
+					tokens = append([]token{{value: tok.attached, pos: tok.pos}}, tokens...)
+				}
+			case tok.hasAttached:
+				if err = flag.set(tok.attached); err != nil {
+					return nil, nil, err
+				}
+			case flag.kind == optionalValueFlag:
+				// only the attached form provides a value for an optional-value flag
+				flag.value = flag.implicitValue
+			case len(tokens) == 0:
+				return nil, nil, BadArgument{Arg: &flag.Arg, Message: "parameter requires an argument, none was provided"}
+			case !isFlag(tokens[0].value) || tokens[0].literal:
+				if err = flag.set(tokens[0].value); err != nil {
+					return nil, nil, err
 				}
+				valuePos = tokens[0].pos
+				tokens = tokens[1:]
 			}
 			if err := validateParams(&flag.Arg); err != nil {
-				return nil, err
+				return nil, nil, err
+			}
+			if err := validateConstraints(&flag.Arg, valuePos); err != nil {
+				return nil, nil, err
 			}
 		} else {
 
-			// process as argument
-			var arg *Arg
-			//var err error
-			for index, argName := range commandConfig.ArgNames {
-				// get argument object stored in the `commandConfig`
-				arg = commandConfig.Args[argName]
+			// process as argument, once the number of arguments is known
+			positionals = append(positionals, tok)
+		}
+	}
 
-				var conval interface{}
-				var err error
-				if conval, err = convert(value, arg.defaultValue); err != nil {
-					return nil, err
-				}
-				var slice reflect.Value
-				if arg.value == nil {
-					if arg.isVariadic {
-						slice = reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(conval)), 0, 0)
-						rval := reflect.New(slice.Type())
-						rval.Elem().Set(slice)
-						sp := reflect.ValueOf(rval.Interface())
-						svp := sp.Elem()
-						arg.value = svp.Interface()
-					} else {
-						arg.value = conval
-						break
-					}
-				}
+	// assign the positional values to the arguments
+	if err := assignArgs(result, positionals, commandConfig.StrictArgs); err != nil {
+		return nil, nil, err
+	}
 
-				// if last argument is a variadic argument, append values
-				if (index == len(commandConfig.ArgNames)-1) && arg.isVariadic {
-					slice = reflect.ValueOf(arg.value)
-					rval := reflect.New(slice.Type())
-					rval.Elem().Set(slice)
-					sp := reflect.ValueOf(rval.Interface())
-					svp := sp.Elem()
-					svp.Set(reflect.Append(svp, reflect.ValueOf(conval)))
-					arg.value = svp.Interface()
-				}
+	// minimum counts apply to lists that got too few values, or none
+	for _, argName := range result.ArgNames {
+		if err := validateCount(result.Args[argName]); err != nil {
+			return nil, nil, err
+		}
+	}
+	flagNames := make([]string, 0, len(result.Flags))
+	for name := range result.Flags {
+		flagNames = append(flagNames, name)
+	}
+	sort.Strings(flagNames)
+	for _, name := range flagNames {
+		if err := validateCount(&result.Flags[name].Arg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return result, flagNames, nil
+}
+
+// assignArgs assigns the positional values to the arguments of the result, in
+// order, each argument taking the number of values given by `matchArgs`. If
+// `strict` is true, a missing value of a required argument is a `BadArgument`
+// error, and a surplus value an `UnexpectedArgument` error.
+func assignArgs(result *ParseResult, positionals []token, strict bool) error {
+	args := make([]*Arg, len(result.ArgNames))
+	for i, argName := range result.ArgNames {
+		args[i] = result.Args[argName]
+	}
+	counts := matchArgs(args, len(positionals))
+
+	// cursor over the positional values
+	cursor := 0
+	for i, arg := range args {
+		if strict && counts[i] == 0 && !arg.optional && !arg.isVariadic {
+			return BadArgument{Arg: arg, Message: "requires a value, none was provided", Position: -1}
+		}
+		for _, tok := range positionals[cursor : cursor+counts[i]] {
+			if err := setArg(arg, tok); err != nil {
+				return err
+			}
+		}
+		cursor += counts[i]
+	}
+
+	if strict && cursor < len(positionals) {
+		return UnexpectedArgument{Value: positionals[cursor].value, Position: positionals[cursor].pos}
+	}
+	return nil
+}
+
+// matchArgs returns the number of values each of the arguments `args` takes
+// from `n` positional values. Required arguments (and the minimum count of a
+// variadic argument) take values first; the remaining values go to the
+// optional arguments, in order, and then to the variadic argument. So a
+// variadic argument may be followed by required arguments, like in
+// `cp SRC... DEST`. If there are too few values, the arguments are filled in
+// order.
+func matchArgs(args []*Arg, n int) []int {
+	required := 0
+	for _, arg := range args {
+		if arg.isVariadic {
+			required += arg.minCount()
+		} else if !arg.optional {
+			required++
+		}
+	}
+
+	counts := make([]int, len(args))
+	extra, left := n-required, n
+	for i, arg := range args {
+		switch {
+		case arg.isVariadic:
+			counts[i] = arg.minCount()
+			if extra > 0 {
+				counts[i] += extra
+				extra = 0
 			}
-			if err := validateParams(arg); err != nil {
-				return nil, err
+		case arg.optional:
+			if extra > 0 {
+				counts[i] = 1
+				extra--
 			}
+		default:
+			counts[i] = 1
+		}
+
+		if counts[i] > left {
+			counts[i] = left
+		}
+		left -= counts[i]
+	}
+	return counts
+}
+
+// setArg converts the positional value `tok` and sets it as the value of the
+// argument, or appends it to the values of a variadic argument.
+func setArg(arg *Arg, tok token) error {
+	conval, err := convert(tok.value, arg)
+	if err != nil {
+		return err
+	}
+	arg.positions = append(arg.positions, tok.pos)
+
+	if arg.isVariadic {
+		if conval == nil {
+			return BadArgument{Arg: arg, Message: fmt.Sprintf("illegal value %v, must be %T", tok.value, arg.defaultValue)}
+		}
+		if arg.value == nil {
+			arg.value = reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(conval)), 0, 1).Interface()
 		}
+		arg.value = reflect.Append(reflect.ValueOf(arg.value), reflect.ValueOf(conval)).Interface()
+	} else {
+		arg.value = conval
 	}
 
-	return commandConfig, nil
+	if err := validateParams(arg); err != nil {
+		return err
+	}
+	return validateConstraints(arg, tok.pos)
 }
 
-func convert(i string, defaults interface{}) (interface{}, error) {
+// convert a command-line argument value `i` to the type of the argument `a`
+func convert(i string, a *Arg) (interface{}, error) {
 	var rv interface{}
 	var err error
 	// The default could be an array of allowed values, and if so,
 	// get one of the elements so we can test the type
+	defaults := a.defaultValue
 	p := reflect.TypeOf(defaults)
-	if p.Kind() == reflect.Slice {
+	if isList(p) || p.Kind() == reflect.Map {
 		p = p.Elem()
 	}
-	timeKind := reflect.TypeOf(time.Now()).Kind()
-	durationKind := reflect.TypeOf(time.Second).Kind()
+	if p.Implements(valueType) {
+		return newValue(defaults, p, i)
+	}
+	// types sharing their kind with other types (e.g. a time.Time is a struct
+	// and a time.Duration is an int64) are matched by their exact types
+	switch p {
+	case timeType:
+		return parseTime(i, a)
+	case durationType:
+		return parseDuration(i)
+	case byteSizeType:
+		return parseByteSize(i)
+	case percentType:
+		return parsePercent(i)
+	case addrType:
+		return parseAddr(i)
+	case prefixType:
+		return parsePrefix(i)
+	case hostPortType:
+		return parseHostPort(i, defaults)
+	case hardwareAddrType:
+		return net.ParseMAC(i)
+	case urlType:
+		return parseURL(i, a)
+	case pathType:
+		return parsePath(i, a)
+	}
 	switch p.Kind() {
 	case reflect.Bool:
 		rv, err = strconv.ParseBool(i)
 	case reflect.String:
 		return i, nil
-	case reflect.Int:
-		rv, err = strconv.Atoi(i)
-	case timeKind:
-		rv, err = time.Parse("2006-01-02 03:04", i)
-	case durationKind:
-		rv, err = time.ParseDuration(i)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var v int64
+		if v, err = strconv.ParseInt(decimalLiteral(i), 0, p.Bits()); err == nil {
+			rv = reflect.ValueOf(v).Convert(p).Interface()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var v uint64
+		if v, err = strconv.ParseUint(decimalLiteral(i), 0, p.Bits()); err == nil {
+			rv = reflect.ValueOf(v).Convert(p).Interface()
+		}
 	case reflect.Float64:
 		rv, err = strconv.ParseFloat(i, 64)
 	default:
+		return nil, fmt.Errorf("unsupported type %v", p)
 	}
 	return rv, err
 }
 
+// supportsType tells if `convert` converts values of type `p`.
+func supportsType(p reflect.Type) bool {
+	if p.Implements(valueType) {
+		return p.Kind() == reflect.Ptr
+	}
+	switch p {
+	case timeType, durationType, byteSizeType, percentType, addrType, prefixType,
+		hostPortType, hardwareAddrType, urlType, pathType:
+		return true
+	}
+	switch p.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// newValue returns a new custom `Value` of the pointer type `p`, set from `s`.
+// The new `Value` is a copy of `defaults`, or of the first allowed value if
+// `defaults` is a slice.
+func newValue(defaults interface{}, p reflect.Type, s string) (Value, error) {
+	if p.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("%v must be a pointer type to be used as a Value", p)
+	}
+
+	prototype := reflect.ValueOf(defaults)
+	if isList(prototype.Type()) && prototype.Len() > 0 {
+		prototype = prototype.Index(0)
+	}
+
+	rv := reflect.New(p.Elem())
+	if prototype.Type() == p && !prototype.IsNil() {
+		rv.Elem().Set(prototype.Elem())
+	}
+
+	v := rv.Interface().(Value)
+	if err := v.Set(s); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // validate the a.value(s) against the a.defaultValue(s)
 // If a.value is a value, it must match the type of a.defaultValue; or,
 // if a.defaultValue is an array, a.value must be in a.defaultValue.
@@ -302,23 +657,31 @@ func convert(i string, defaults interface{}) (interface{}, error) {
 // if a.defaultValue is an array, every element in a.value mut be found in a.defaultValue.
 func validateParams(a *Arg) error {
 	if a.value == nil {
-		return BadArgument{a, "parameter requires argument"}
+		return BadArgument{Arg: a, Message: "parameter requires argument"}
 	}
 	p := reflect.TypeOf(a.value)
 	pv := reflect.ValueOf(a.value)
+	// if a.value is a map, its values have been converted to the type of the
+	// a.defaultValue map values
+	if p.Kind() == reflect.Map {
+		if p != reflect.TypeOf(a.defaultValue) {
+			return BadArgument{Arg: a, Message: fmt.Sprintf("illegal value %v, must be %T", a.value, a.defaultValue)}
+		}
+		return nil
+	}
 	// if a.value is an array, check each element against a.defaultValues
-	if p.Kind() == reflect.Slice {
+	if isList(p) {
 		for i := 0; i < pv.Len(); i++ {
 			v := pv.Index(i).Interface()
 			if !validateElement(v, a.defaultValue) {
-				return BadArgument{a, fmt.Sprintf("illegal value %v, must be %v", v, a.defaultValue)}
+				return BadArgument{Arg: a, Message: fmt.Sprintf("illegal value %v, must be %v", v, a.defaultValue)}
 			}
 		}
 		return nil
 	} else {
 		// if a.value is not an array, test it against a.defaultValue
 		if !validateElement(a.value, a.defaultValue) {
-			return BadArgument{a, fmt.Sprintf("illegal value %v, must be %v", a.value, a.defaultValue)}
+			return BadArgument{Arg: a, Message: fmt.Sprintf("illegal value %v, must be %v", a.value, a.defaultValue)}
 		}
 	}
 	return nil
@@ -329,10 +692,10 @@ func validateElement(val interface{}, vals interface{}) bool {
 	p := reflect.TypeOf(vals)
 	pv := reflect.ValueOf(vals)
 	// if vals is an array, val must be in it
-	if p.Kind() == reflect.Slice {
+	if isList(p) {
 		for i := 0; i < pv.Len(); i++ {
 			v := pv.Index(i).Interface()
-			if val == v {
+			if equalValues(val, v) {
 				return true
 			}
 		}
@@ -343,14 +706,67 @@ func validateElement(val interface{}, vals interface{}) bool {
 	}
 }
 
+// equalValues checks whether two single values are equal. Custom values are
+// compared by their string representations, and network values semantically
+// (see `equalNetValues`).
+func equalValues(a interface{}, b interface{}) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if _a, ok := a.(Value); ok {
+		return _a.String() == b.(Value).String()
+	}
+	if equal, ok := equalNetValues(a, b); ok {
+		return equal
+	}
+	if !reflect.TypeOf(a).Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// isList checks whether `p` is the type of a list of values (allowed values,
+// or the values of a variadic argument or a list flag) rather than the type of
+// a single value which happens to be a slice (like a `net.HardwareAddr`).
+func isList(p reflect.Type) bool {
+	return p != nil && p.Kind() == reflect.Slice && p != hardwareAddrType
+}
+
+// Value is the interface of custom argument and flag types, like `flag.Value`.
+// A non-nil pointer to a value of a custom type (or a slice of such pointers,
+// giving the allowed values) can be passed to `AddArg` or `AddFlag` as the
+// default value. Every parsed value is a new `Value` (a copy of the default
+// value, or of the first allowed value) set from the command-line argument
+// value, so the default value is never modified.
+type Value interface {
+	// Set sets the value from a command-line argument value, or returns an
+	// error if it is not a valid value. It is called by `Registry.Parse` with
+	// the registry locked, so it must not use the registry, its commands, or
+	// their arguments and flags.
+	Set(string) error
+
+	// String formats the value, e.g. to show a default value in a help text
+	String() string
+
+	// Type returns the name of the type of values, e.g. to show in a help text
+	Type() string
+}
+
+// reflect type of the `Value` interface
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
 /*---------------------*/
 
-// CommandConfig type holds the structure and values of the command-line arguments of command.
+// CommandConfig type holds the structure of the command-line arguments of command.
+// The values of parsed command-line arguments are held by a "*ParseResult" object.
 type CommandConfig struct {
 
 	// name of the sub-command ("" for the root command)
 	Name string
 
+	// description of the sub-command, e.g. to show in a help text
+	Description string
+
 	// command-line flags
 	Flags map[string]*Flag
 
@@ -362,6 +778,48 @@ type CommandConfig struct {
 
 	// list of the argument names (for ordered iteration)
 	ArgNames []string
+
+	// if true, a long flag may be abbreviated to any unambiguous prefix of
+	// its name, getopt_long style (e.g. `--verb` for `--verbose`)
+	AbbreviateFlags bool
+
+	// if true, positional values beyond the registered arguments are an
+	// `UnexpectedArgument` error, and a missing value of a required argument
+	// (neither optional nor variadic) is a `BadArgument` error; otherwise,
+	// surplus values are ignored, and missing arguments have no value
+	StrictArgs bool
+
+	// registry the command is registered with
+	registry *Registry
+
+	// validators of the parsed values (see `AddValidator`)
+	validators []func(*ParseResult) error
+
+	// errors of flags which could not be registered (see `Registry.Validate`)
+	problems []SchemaError
+}
+
+// lock locks the registry of the command to register an argument or a flag.
+// If the registry is frozen, it returns `ErrFrozen`; the registry is locked
+// anyway, so the mistake can be recorded before calling `unlock`.
+func (commandConfig *CommandConfig) lock() error {
+	if commandConfig.registry == nil {
+		return nil
+	}
+
+	commandConfig.registry.mu.Lock()
+	if commandConfig.registry.frozen {
+		return ErrFrozen
+	}
+
+	return nil
+}
+
+// unlock unlocks the registry of the command locked by `lock`.
+func (commandConfig *CommandConfig) unlock() {
+	if commandConfig.registry != nil {
+		commandConfig.registry.mu.Unlock()
+	}
 }
 
 // AddArg registers an argument configuration with the command.
@@ -370,10 +828,17 @@ type CommandConfig struct {
 //   - If value of the `name` argument ends with `...` suffix, then it is a
 //     variadic argument.
 //   - If the argument is already registered, second return value will be `true`.
-//   - Variadic argument can accept multiple argument values and it should be the
-//     last registered argument.
+//   - Variadic argument can accept multiple argument values. Only one argument
+//     can be variadic, and it can be followed by other arguments, like `DEST`
+//     in `cp SRC... DEST`.
 //   - Values of a variadic argument will be returned as an array.
-//   - All arguments without a default value must be registered first.
+//   - Positional values starting with `-` are flags, except negative numbers
+//     and durations (like `-5` or `-2h`) not starting with a registered short
+//     flag, and the values following `--`.
+//   - Arguments marked with `Arg.Optional` only take a value if there are more
+//     values than required arguments; they must not follow a variadic argument.
+//   - A second variadic argument is not registered, and the mistake is
+//     recorded, so `Registry.Validate` returns it.
 //   - If an argument with given `name` is already registered, then argument
 //     registration is skipped and registered `*Arg` object returned.
 //   - The `defaultValue` argument represents the default value of the argument,
@@ -381,17 +846,37 @@ type CommandConfig struct {
 //
 // Supported types are:
 //
-//   - int
+//   - int, int8, int16, int32, int64
+//   - uint, uint8, uint16, uint32, uint64
 //   - string
 //   - float64
 //   - bool
-//   - time.Time
-//   - time.Duration
+//   - time.Time (see `DefaultTimeLayouts`), or a relative time like `now`,
+//     `-2h`, `yesterday` or `2026-10-01T00:00Z+3d` (see `Registry.SetClock`)
+//   - time.Duration, with days and weeks like `3d`, `2w` or `1d12h` too
+//   - ByteSize, like `512MiB` or `1.5G`
+//   - Percent, like `75%`
+//   - netip.Addr, like `10.0.0.1` or `::1`
+//   - netip.Prefix, like `10.0.0.0/8`
+//   - HostPort, like `example.com:443`
+//   - net.HardwareAddr, like `00:00:5e:00:53:01`
+//   - *url.URL, like `https://example.com` (see `Arg.WithSchemes`)
+//   - Path, like `~/.config` (see `Arg.WithPathChecks`)
+//   - a pointer to a custom type implementing `Value`
+//
+// Integers are range-checked for their type, and can be written as hexadecimal
+// (`0x1f`), octal (`0o17`) or binary (`0b1010`) literals, with `_` separators
+// (`1_000_000`).
 //
 // If the provided defaultValue is an array of one of the above types, then that
 // array defines a set of legal values; any provided parameter that doesn't
 // match a value in the array will result in a parse error.
+//
+// If the registry of the command is frozen, the argument is not registered,
+// and `ErrFrozen` is recorded, so `Registry.Validate` returns it.
 func (commandConfig *CommandConfig) AddArg(name string, defaultValue interface{}) *Arg {
+	frozen := commandConfig.lock()
+	defer commandConfig.unlock()
 
 	// clean argument values
 	name = removeWhitespaces(name)
@@ -401,7 +886,7 @@ func (commandConfig *CommandConfig) AddArg(name string, defaultValue interface{}
 		return _arg
 	}
 
-	rv := Arg{Name: name}
+	rv := Arg{Name: name, command: commandConfig}
 
 	if v, ok := defaultValue.(string); ok {
 		rv.defaultValue = trimWhitespaces(v)
@@ -415,6 +900,18 @@ func (commandConfig *CommandConfig) AddArg(name string, defaultValue interface{}
 		rv.isVariadic = true
 	}
 
+	// a frozen registry records the argument as a mistake instead of registering it
+	if frozen != nil {
+		commandConfig.problems = append(commandConfig.problems, SchemaError{Command: commandConfig.Name, Arg: rv.Name, Message: frozen.Error()})
+		return &rv
+	}
+
+	// the values of the arguments must be unambiguous: only one argument can be variadic
+	if variadic := commandConfig.variadicBefore(rv.Name); rv.isVariadic && variadic != "" {
+		commandConfig.problems = append(commandConfig.problems, SchemaError{Command: commandConfig.Name, Arg: rv.Name, Message: fmt.Sprintf("variadic arguments %s and %s are ambiguous", variadic, rv.Name)})
+		return &rv
+	}
+
 	// register argument with the command-config
 	commandConfig.Args[rv.Name] = &rv
 
@@ -440,7 +937,30 @@ func (commandConfig *CommandConfig) AddArg(name string, defaultValue interface{}
 //     inverted
 //   - Registering a non-boolean inverted flag will produce an error
 //   - Boolean flag defaults are preserved, but have no effect on the `AsBool()` result.
+//   - If `defaultValue` is a map with string keys (e.g. `map[string]string{}`),
+//     the flag takes a `key=value` entry every time it is provided, like
+//     `--label app=web --label tier=db`. Entry values are converted to the type
+//     of the map values, and a duplicate key is a parse error.
+//   - A non-boolean flag takes the next command-line argument as its value,
+//     even if it starts with `-` (like `--since -2h`), unless it is a
+//     registered flag.
+//   - If the short name is already used by another flag, it returns an error.
+//   - If the registry of the command is frozen, it returns `ErrFrozen`.
+//   - Errors are recorded, so `Registry.Validate` returns them too.
 func (commandConfig *CommandConfig) AddFlag(name string, shortName string, defaultValue interface{}) (*Flag, error) {
+	err := commandConfig.lock()
+	defer commandConfig.unlock()
+	if err != nil {
+		return nil, commandConfig.record(name, err)
+	}
+
+	flag, err := commandConfig.addFlag(name, shortName, defaultValue)
+	return flag, commandConfig.record(name, err)
+}
+
+// addFlag registers a command-line flag with the command (see `AddFlag`).
+// The registry of the command must be locked.
+func (commandConfig *CommandConfig) addFlag(name string, shortName string, defaultValue interface{}) (*Flag, error) {
 	// clean argument values
 	name = removeWhitespaces(name)
 
@@ -463,6 +983,15 @@ func (commandConfig *CommandConfig) AddFlag(name string, shortName string, defau
 	}
 	rv.Name = name
 	rv.defaultValue = defaultValue
+	rv.command = commandConfig
+
+	// a map default value makes a flag taking `key=value` entries
+	if p := reflect.TypeOf(defaultValue); p != nil && p.Kind() == reflect.Map {
+		if p.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map flags must have string keys")
+		}
+		rv.kind = mapFlag
+	}
 
 	switch v := defaultValue.(type) {
 	case bool:
@@ -491,6 +1020,9 @@ func (commandConfig *CommandConfig) AddFlag(name string, shortName string, defau
 			return nil, fmt.Errorf("inverted flags may not have short versions")
 		}
 		rv.ShortName = rv.ShortName[:1]
+		if other, ok := commandConfig.flagsShort[rv.ShortName]; ok {
+			return nil, fmt.Errorf("short name %s is already used by flag %s", rv.ShortName, other)
+		}
 		commandConfig.flagsShort[rv.ShortName] = rv.Name
 	}
 
@@ -500,98 +1032,443 @@ func (commandConfig *CommandConfig) AddFlag(name string, shortName string, defau
 	return &rv, nil
 }
 
-// Arg type holds the structured information about an argument.
-type Arg struct {
-	// name of the argument
-	Name string
-
-	isVariadic   bool
-	defaultValue interface{}
-	value        interface{}
+// shortFlagTakesValue checks whether the flag registered with the short name
+// `name` takes a value from the command-line arguments.
+func (commandConfig *CommandConfig) shortFlagTakesValue(name string) bool {
+	flagName, ok := commandConfig.flagsShort[name]
+	if !ok {
+		return false
+	}
+	_, isBool := commandConfig.Flags[flagName].defaultValue.(bool)
+	return !isBool
 }
 
-func (a Arg) AsInt() int {
-	if v, ok := a.value.(int); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.(int)
-		return v
+// lookupLongFlag finds the registered flag for a long flag `value` such as
+// `--verbose` or `--no-clean`. The second return value is `true` if the flag
+// was given in its inverted `no-` form. If `AbbreviateFlags` is set and there
+// is no exact match, a unique prefix of a flag name (or of its inverted form)
+// is accepted; a prefix matching several flags returns an `AmbiguousFlag` error.
+func (commandConfig *CommandConfig) lookupLongFlag(value string) (*Flag, bool, error) {
+	name := strings.TrimLeft(value, "-")
+
+	if flag, isInv := commandConfig.exactLongFlag(name); flag != nil {
+		return flag, isInv, nil
 	}
-}
 
-func (a Arg) AsTime() time.Time {
-	if v, ok := a.value.(time.Time); ok {
-		return v
-	} else {
-		v, _ := a.defaultValue.(time.Time)
-		return v
+	if !commandConfig.AbbreviateFlags {
+		return nil, false, UnknownFlag{value}
 	}
-}
 
-func (a Arg) AsDuration() time.Duration {
-	if v, ok := a.value.(time.Duration); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.(time.Duration)
-		return v
+	// collect every flag name (and inverted boolean flag name) starting with `name`
+	matches := make([]string, 0)
+	for flagName, flag := range commandConfig.Flags {
+		if strings.HasPrefix(flagName, name) {
+			matches = append(matches, flagName)
+		}
+		if _, isBool := flag.defaultValue.(bool); isBool && strings.HasPrefix("no-"+flagName, name) {
+			matches = append(matches, "no-"+flagName)
+		}
 	}
-}
 
-func (a Arg) AsBool() bool {
-	if v, ok := a.value.(bool); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.(bool)
-		return v
+	switch len(matches) {
+	case 0:
+		return nil, false, UnknownFlag{value}
+	case 1:
+		flag, isInv := commandConfig.exactLongFlag(matches[0])
+		return flag, isInv, nil
 	}
-}
 
-func (a Arg) AsString() string {
-	if v, ok := a.value.(string); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.(string)
-		return v
+	sort.Strings(matches)
+	for i, match := range matches {
+		matches[i] = "--" + match
 	}
+	return nil, false, AmbiguousFlag{value, matches}
 }
 
-func (a Arg) AsFloat() float64 {
-	if v, ok := a.value.(float64); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.(float64)
-		return v
+// exactLongFlag returns the flag registered under `name`, or under `name`
+// without its `no-` prefix (in which case the second return value is `true`).
+func (commandConfig *CommandConfig) exactLongFlag(name string) (*Flag, bool) {
+	if flag, ok := commandConfig.Flags[name]; ok {
+		return flag, false
+	}
+	if strings.HasPrefix(name, "no-") {
+		if flag, ok := commandConfig.Flags[name[3:]]; ok {
+			return flag, true
+		}
 	}
+	return nil, false
 }
 
-func (a Arg) AsInts() []int {
-	if v, ok := a.value.([]int); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.([]int)
-		return v
+// AddOptionalFlag method registers a command-line flag whose value is
+// optional, like `--color[=WHEN]` (getopt "::" semantics).
+//
+// The rules are the same as for `AddFlag`, but in addition:
+//
+//   - Only a value attached with `=` (e.g. `--color=always` or `-c=always`) is
+//     taken as the value of the flag; the next command-line argument is never
+//     consumed.
+//   - If the flag is provided without an attached value, it takes the
+//     `implicitValue`, which must be of the type of `defaultValue` (or one of
+//     its allowed values).
+//   - Boolean flags can not take optional values.
+func (commandConfig *CommandConfig) AddOptionalFlag(name string, shortName string, defaultValue interface{}, implicitValue interface{}) (*Flag, error) {
+	err := commandConfig.lock()
+	defer commandConfig.unlock()
+	if err != nil {
+		return nil, commandConfig.record(name, err)
 	}
+
+	flag, err := commandConfig.addOptionalFlag(name, shortName, defaultValue, implicitValue)
+	return flag, commandConfig.record(name, err)
 }
 
-func (a Arg) AsTimes() []time.Time {
-	if v, ok := a.value.([]time.Time); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.([]time.Time)
-		return v
+// addOptionalFlag registers a command-line flag whose value is optional (see
+// `AddOptionalFlag`). The registry of the command must be locked.
+func (commandConfig *CommandConfig) addOptionalFlag(name string, shortName string, defaultValue interface{}, implicitValue interface{}) (*Flag, error) {
+	if _, ok := defaultValue.(bool); ok {
+		return nil, fmt.Errorf("boolean flags can not take optional values")
+	}
+	if !validateElement(implicitValue, defaultValue) {
+		return nil, fmt.Errorf("illegal implicit value %v, must be %v", implicitValue, defaultValue)
 	}
-}
 
-func (a Arg) AsDurations() []time.Duration {
-	if v, ok := a.value.([]time.Duration); ok {
-		return v
-	} else {
-		v, _ = a.defaultValue.([]time.Duration)
-		return v
+	// return if flag is already registered
+	if _flag, ok := commandConfig.Flags[removeWhitespaces(name)]; ok {
+		return _flag, nil
 	}
-}
 
-func (a Arg) AsBools() []bool {
+	flag, err := commandConfig.addFlag(name, shortName, defaultValue)
+	if err != nil {
+		return nil, err
+	}
+
+	flag.kind = optionalValueFlag
+	flag.implicitValue = implicitValue
+
+	return flag, nil
+}
+
+// AddListFlag method registers a command-line flag taking a list of values
+// separated by `separator` (`,` if empty), like `--hosts a,b,c`.
+//
+// The rules are the same as for `AddFlag`, but in addition:
+//
+//   - The value of the flag is a slice of the type of `defaultValue` (e.g. a
+//     `[]int` for an `int` default), or of the type of the allowed values if
+//     `defaultValue` is a slice. Every element is checked against the allowed
+//     values.
+//   - Values accumulate if the flag is provided several times, so `--hosts a,b
+//     --hosts c` is the same as `--hosts a,b,c`.
+//   - A backslash escapes the next character, so `a\,b` is the single element
+//     `a,b` and `a\\` is `a\`.
+//   - Boolean flags can not be lists.
+func (commandConfig *CommandConfig) AddListFlag(name string, shortName string, defaultValue interface{}, separator string) (*Flag, error) {
+	err := commandConfig.lock()
+	defer commandConfig.unlock()
+	if err != nil {
+		return nil, commandConfig.record(name, err)
+	}
+
+	flag, err := commandConfig.addListFlag(name, shortName, defaultValue, separator)
+	return flag, commandConfig.record(name, err)
+}
+
+// addListFlag registers a command-line flag taking a list of values (see
+// `AddListFlag`). The registry of the command must be locked.
+func (commandConfig *CommandConfig) addListFlag(name string, shortName string, defaultValue interface{}, separator string) (*Flag, error) {
+	if _, ok := defaultValue.(bool); ok {
+		return nil, fmt.Errorf("boolean flags can not be lists")
+	}
+
+	// return if flag is already registered
+	if _flag, ok := commandConfig.Flags[removeWhitespaces(name)]; ok {
+		return _flag, nil
+	}
+
+	flag, err := commandConfig.addFlag(name, shortName, defaultValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if separator == "" {
+		separator = ","
+	}
+	flag.kind = listFlag
+	flag.separator = separator
+
+	return flag, nil
+}
+
+// ParseResult type holds the values of the command-line arguments parsed for a command.
+type ParseResult struct {
+
+	// name of the sub-command ("" for the root command)
+	Name string
+
+	// command-line flags with their values
+	Flags map[string]*Flag
+
+	// command arguments with their values
+	Args map[string]*Arg
+
+	// list of the argument names (for ordered iteration)
+	ArgNames []string
+
+	// registered configuration of the command
+	Command *CommandConfig
+
+	// validators of the command when the result was created
+	validators []func(*ParseResult) error
+}
+
+// Visit calls `fn` for each flag provided in the command-line arguments, in
+// lexicographical order of the flag names.
+func (result *ParseResult) Visit(fn func(*Flag)) {
+	names := make([]string, 0, len(result.Flags))
+	for name, flag := range result.Flags {
+		if flag.IsSet() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fn(result.Flags[name])
+	}
+}
+
+// newParseResult returns a `*ParseResult` holding copies of the flags and
+// arguments registered with `commandConfig`, without values, reading the
+// current time from the `now` clock.
+func newParseResult(commandConfig *CommandConfig, now func() time.Time) *ParseResult {
+	result := &ParseResult{
+		Name:     commandConfig.Name,
+		Flags:    make(map[string]*Flag, len(commandConfig.Flags)),
+		Args:     make(map[string]*Arg, len(commandConfig.Args)),
+		ArgNames: append(make([]string, 0, len(commandConfig.ArgNames)), commandConfig.ArgNames...),
+		Command:  commandConfig,
+
+		validators: commandConfig.validators,
+	}
+
+	for name, flag := range commandConfig.Flags {
+		_flag := *flag
+		_flag.value, _flag.positions, _flag.now = nil, nil, now
+		result.Flags[name] = &_flag
+	}
+
+	for name, arg := range commandConfig.Args {
+		_arg := *arg
+		_arg.value, _arg.positions, _arg.now = nil, nil, now
+		result.Args[name] = &_arg
+	}
+
+	return result
+}
+
+// Arg type holds the structured information about an argument.
+type Arg struct {
+	// name of the argument
+	Name string
+
+	// description of the argument, e.g. to show in a help text
+	Description string
+
+	isVariadic   bool
+	defaultValue interface{}
+	value        interface{}
+
+	// positions in the command-line arguments where the argument was provided
+	positions []int
+
+	// layouts and location of time values
+	timeLayouts []string
+	location    *time.Location
+
+	// clock of relative time values
+	now func() time.Time
+
+	// allowed schemes of URL values
+	schemes []string
+
+	// checks of path values
+	pathChecks PathCheck
+
+	// if true, the argument only takes a surplus positional value
+	optional bool
+
+	// command the argument is registered with
+	command *CommandConfig
+
+	// constraints of the values (see validators.go)
+	min, max      interface{}
+	length, count *bounds
+	pattern       *regexp.Regexp
+	patternSource string
+	validators    []func(value interface{}) error
+}
+
+// WithTimeLayouts sets the layouts (see `time.Parse`) of the time values of
+// the argument, tried in order, instead of the `DefaultTimeLayouts`.
+func (a *Arg) WithTimeLayouts(layouts ...string) *Arg {
+	return a.update(func() { a.timeLayouts = layouts })
+}
+
+// WithLocation sets the location of the time values of the argument that
+// don't specify a time zone (UTC by default).
+func (a *Arg) WithLocation(location *time.Location) *Arg {
+	return a.update(func() { a.location = location })
+}
+
+// Optional marks the argument as optional: it only takes a value if there are
+// more positional values than required arguments (see `CommandConfig.AddArg`).
+// An argument following a variadic argument can't be optional; the mistake is
+// recorded, so `Registry.Validate` returns it.
+func (a *Arg) Optional() *Arg {
+	return a.update(func() {
+		if a.command != nil {
+			if variadic := a.command.variadicBefore(a.Name); variadic != "" {
+				a.record(fmt.Sprintf("optional argument must not follow variadic argument %s", variadic))
+				return
+			}
+		}
+		a.optional = true
+	})
+}
+
+// variadicBefore returns the name of the variadic argument registered before
+// the argument `name` (or before any new argument), or "" if there is none.
+func (commandConfig *CommandConfig) variadicBefore(name string) string {
+	for _, argName := range commandConfig.ArgNames {
+		if argName == name {
+			break
+		}
+		if commandConfig.Args[argName].isVariadic {
+			return argName
+		}
+	}
+	return ""
+}
+
+// update calls `set` to change the configuration of the argument, with the
+// registry of its command locked. If the registry is frozen, the argument is
+// unchanged, and `ErrFrozen` is recorded, so `Registry.Validate` returns it.
+func (a *Arg) update(set func()) *Arg {
+	commandConfig := a.command
+	if commandConfig == nil {
+		set()
+		return a
+	}
+
+	err := commandConfig.lock()
+	defer commandConfig.unlock()
+	if err != nil {
+		a.record(err.Error())
+		return a
+	}
+
+	set()
+	return a
+}
+
+// IsSet returns `true` if the argument was provided in the command-line arguments.
+func (a Arg) IsSet() bool {
+	return len(a.positions) > 0
+}
+
+// Occurrences returns the number of times the argument was provided in the command-line arguments.
+func (a Arg) Occurrences() int {
+	return len(a.positions)
+}
+
+// Positions returns the positions (indexes of the values passed to `Parse`)
+// where the argument was provided in the command-line arguments.
+func (a Arg) Positions() []int {
+	return append([]int(nil), a.positions...)
+}
+
+func (a Arg) AsInt() int {
+	if v, ok := a.value.(int); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(int)
+		return v
+	}
+}
+
+func (a Arg) AsTime() time.Time {
+	if v, ok := a.value.(time.Time); ok {
+		return v
+	} else {
+		v, _ := a.defaultValue.(time.Time)
+		return v
+	}
+}
+
+func (a Arg) AsDuration() time.Duration {
+	if v, ok := a.value.(time.Duration); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(time.Duration)
+		return v
+	}
+}
+
+func (a Arg) AsBool() bool {
+	if v, ok := a.value.(bool); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(bool)
+		return v
+	}
+}
+
+func (a Arg) AsString() string {
+	if v, ok := a.value.(string); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(string)
+		return v
+	}
+}
+
+func (a Arg) AsFloat() float64 {
+	if v, ok := a.value.(float64); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(float64)
+		return v
+	}
+}
+
+func (a Arg) AsInts() []int {
+	if v, ok := a.value.([]int); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]int)
+		return v
+	}
+}
+
+func (a Arg) AsTimes() []time.Time {
+	if v, ok := a.value.([]time.Time); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]time.Time)
+		return v
+	}
+}
+
+func (a Arg) AsDurations() []time.Duration {
+	if v, ok := a.value.([]time.Duration); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]time.Duration)
+		return v
+	}
+}
+
+func (a Arg) AsBools() []bool {
 	if v, ok := a.value.([]bool); ok {
 		return v
 	} else {
@@ -618,66 +1495,769 @@ func (a Arg) AsFloats() []float64 {
 	}
 }
 
+func (a Arg) AsInt8() int8 {
+	if v, ok := a.value.(int8); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(int8)
+		return v
+	}
+}
+
+func (a Arg) AsInt16() int16 {
+	if v, ok := a.value.(int16); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(int16)
+		return v
+	}
+}
+
+func (a Arg) AsInt32() int32 {
+	if v, ok := a.value.(int32); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(int32)
+		return v
+	}
+}
+
+func (a Arg) AsInt64() int64 {
+	if v, ok := a.value.(int64); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(int64)
+		return v
+	}
+}
+
+func (a Arg) AsUint() uint {
+	if v, ok := a.value.(uint); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(uint)
+		return v
+	}
+}
+
+func (a Arg) AsUint8() uint8 {
+	if v, ok := a.value.(uint8); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(uint8)
+		return v
+	}
+}
+
+func (a Arg) AsUint16() uint16 {
+	if v, ok := a.value.(uint16); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(uint16)
+		return v
+	}
+}
+
+func (a Arg) AsUint32() uint32 {
+	if v, ok := a.value.(uint32); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(uint32)
+		return v
+	}
+}
+
+func (a Arg) AsUint64() uint64 {
+	if v, ok := a.value.(uint64); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(uint64)
+		return v
+	}
+}
+
+func (a Arg) AsInt8s() []int8 {
+	if v, ok := a.value.([]int8); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]int8)
+		return v
+	}
+}
+
+func (a Arg) AsInt16s() []int16 {
+	if v, ok := a.value.([]int16); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]int16)
+		return v
+	}
+}
+
+func (a Arg) AsInt32s() []int32 {
+	if v, ok := a.value.([]int32); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]int32)
+		return v
+	}
+}
+
+func (a Arg) AsInt64s() []int64 {
+	if v, ok := a.value.([]int64); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]int64)
+		return v
+	}
+}
+
+func (a Arg) AsUints() []uint {
+	if v, ok := a.value.([]uint); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]uint)
+		return v
+	}
+}
+
+func (a Arg) AsUint8s() []uint8 {
+	if v, ok := a.value.([]uint8); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]uint8)
+		return v
+	}
+}
+
+func (a Arg) AsUint16s() []uint16 {
+	if v, ok := a.value.([]uint16); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]uint16)
+		return v
+	}
+}
+
+func (a Arg) AsUint32s() []uint32 {
+	if v, ok := a.value.([]uint32); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]uint32)
+		return v
+	}
+}
+
+func (a Arg) AsUint64s() []uint64 {
+	if v, ok := a.value.([]uint64); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]uint64)
+		return v
+	}
+}
+
+// AsMap returns the entries of a map flag, keyed by their string keys.
+func (a Arg) AsMap() map[string]interface{} {
+	entries := reflect.ValueOf(a.value)
+	if entries.Kind() != reflect.Map {
+		entries = reflect.ValueOf(a.defaultValue)
+	}
+	if entries.Kind() != reflect.Map {
+		return nil
+	}
+
+	rv := make(map[string]interface{}, entries.Len())
+	for iter := entries.MapRange(); iter.Next(); {
+		rv[iter.Key().String()] = iter.Value().Interface()
+	}
+	return rv
+}
+
+func (a Arg) AsStringMap() map[string]string {
+	if v, ok := a.value.(map[string]string); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(map[string]string)
+		return v
+	}
+}
+
+// get returns the value of the argument, or its default value if it was not
+// provided, if it is of type `t`. Otherwise, it returns a `WrongType` error,
+// or a `MissingValue` error if there is no value to return.
+func (a Arg) get(t reflect.Type) (interface{}, error) {
+	if a.value != nil {
+		if reflect.TypeOf(a.value) != t {
+			return nil, WrongType{&a, t}
+		}
+		return a.value, nil
+	}
+
+	p := reflect.TypeOf(a.defaultValue)
+	switch {
+	case p == t:
+		return a.defaultValue, nil
+	case p == nil, isList(p) && p.Elem() == t, isList(t) && t.Elem() == p:
+		// no default value, or a default value giving the allowed values or
+		// the type of the values of a variadic argument or a list flag
+		return nil, MissingValue{&a}
+	}
+	return nil, WrongType{&a, t}
+}
+
+// Get stores the value of the argument, or its default value if it was not
+// provided, in the value pointed to by `dst`, e.g.:
+//
+//	var port int
+//	err := flag.Get(&port)
+//
+// It returns a `WrongType` error if the argument is not of the type of `*dst`,
+// or a `MissingValue` error if the argument has no value.
+func (a Arg) Get(dst interface{}) error {
+	p := reflect.ValueOf(dst)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		return fmt.Errorf("%s can not be stored in %T, a non-nil pointer is required", a.Name, dst)
+	}
+
+	v, err := a.get(p.Type().Elem())
+	if err != nil {
+		return err
+	}
+	p.Elem().Set(reflect.ValueOf(v))
+	return nil
+}
+
+// MustGet is like Get, but panics with the error.
+func (a Arg) MustGet(dst interface{}) {
+	if err := a.Get(dst); err != nil {
+		panic(err)
+	}
+}
+
+func (a Arg) AsIntE() (int, error) {
+	v, err := a.get(reflect.TypeOf((*int)(nil)).Elem())
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+func (a Arg) AsTimeE() (time.Time, error) {
+	v, err := a.get(reflect.TypeOf((*time.Time)(nil)).Elem())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return v.(time.Time), nil
+}
+
+func (a Arg) AsDurationE() (time.Duration, error) {
+	v, err := a.get(reflect.TypeOf((*time.Duration)(nil)).Elem())
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+func (a Arg) AsBoolE() (bool, error) {
+	v, err := a.get(reflect.TypeOf((*bool)(nil)).Elem())
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func (a Arg) AsStringE() (string, error) {
+	v, err := a.get(reflect.TypeOf((*string)(nil)).Elem())
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func (a Arg) AsFloatE() (float64, error) {
+	v, err := a.get(reflect.TypeOf((*float64)(nil)).Elem())
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (a Arg) AsIntsE() ([]int, error) {
+	v, err := a.get(reflect.TypeOf((*[]int)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]int), nil
+}
+
+func (a Arg) AsTimesE() ([]time.Time, error) {
+	v, err := a.get(reflect.TypeOf((*[]time.Time)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]time.Time), nil
+}
+
+func (a Arg) AsDurationsE() ([]time.Duration, error) {
+	v, err := a.get(reflect.TypeOf((*[]time.Duration)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]time.Duration), nil
+}
+
+func (a Arg) AsBoolsE() ([]bool, error) {
+	v, err := a.get(reflect.TypeOf((*[]bool)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]bool), nil
+}
+
+func (a Arg) AsStringsE() ([]string, error) {
+	v, err := a.get(reflect.TypeOf((*[]string)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+func (a Arg) AsFloatsE() ([]float64, error) {
+	v, err := a.get(reflect.TypeOf((*[]float64)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return v.([]float64), nil
+}
+
+func (a Arg) MustInt() int {
+	v, err := a.AsIntE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustTime() time.Time {
+	v, err := a.AsTimeE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustDuration() time.Duration {
+	v, err := a.AsDurationE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustBool() bool {
+	v, err := a.AsBoolE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustString() string {
+	v, err := a.AsStringE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustFloat() float64 {
+	v, err := a.AsFloatE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustInts() []int {
+	v, err := a.AsIntsE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustTimes() []time.Time {
+	v, err := a.AsTimesE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustDurations() []time.Duration {
+	v, err := a.AsDurationsE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustBools() []bool {
+	v, err := a.AsBoolsE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustStrings() []string {
+	v, err := a.AsStringsE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (a Arg) MustFloats() []float64 {
+	v, err := a.AsFloatsE()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// AsValue returns the custom `Value` of the argument, or its default value if it was not provided.
+func (a Arg) AsValue() Value {
+	if v, ok := a.value.(Value); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(Value)
+		return v
+	}
+}
+
+// TypeName returns the name of the type of the argument values, e.g. to show in a help text.
+// The type of a custom `Value` is named by its `Type` method.
+func (a Arg) TypeName() string {
+	p := reflect.TypeOf(a.defaultValue)
+	if p == nil {
+		return ""
+	}
+	if isList(p) || p.Kind() == reflect.Map {
+		p = p.Elem()
+	}
+	if p.Implements(valueType) && p.Kind() == reflect.Ptr {
+		if v, ok := a.defaultValue.(Value); ok {
+			return v.Type()
+		}
+		return reflect.New(p.Elem()).Interface().(Value).Type()
+	}
+	return p.String()
+}
+
+// DefaultString formats the default value of the argument, e.g. to show in a
+// help text. Allowed values are separated by `|`, and custom `Value`s are
+// formatted by their `String` method.
+func (a Arg) DefaultString() string {
+	p := reflect.ValueOf(a.defaultValue)
+	if !isList(reflect.TypeOf(a.defaultValue)) {
+		return formatValue(a.defaultValue)
+	}
+
+	values := make([]string, p.Len())
+	for i := range values {
+		values[i] = formatValue(p.Index(i).Interface())
+	}
+	return strings.Join(values, "|")
+}
+
 // Flag type holds the structured information about a flag.
 type Flag struct {
 	Arg
 	// short name of the flag
 	ShortName string
+
+	kind          flagKind
+	implicitValue interface{}
+	separator     string
+}
+
+// flagKind determines how a flag takes its value from the command-line arguments
+type flagKind int
+
+const (
+	// the flag requires a value (unless it is a boolean flag)
+	valueFlag flagKind = iota
+
+	// the flag takes a value only in the attached `--flag=value` form, and
+	// its implicit value otherwise
+	optionalValueFlag
+
+	// the flag takes a `key=value` entry every time it is provided
+	mapFlag
+
+	// the flag takes a list of separated values every time it is provided
+	listFlag
+)
+
+// set converts the command-line argument value `s` and stores it as the value of the flag.
+func (f *Flag) set(s string) error {
+	switch f.kind {
+	case mapFlag:
+		return f.setMapEntry(s)
+	case listFlag:
+		return f.appendList(s)
+	}
+
+	value, err := convert(s, &f.Arg)
+	if err != nil {
+		return err
+	}
+	f.value = value
+	return nil
+}
+
+// appendList converts the elements of the list `s` and appends them to the value of the flag.
+func (f *Flag) appendList(s string) error {
+	// the element type is the type of the default value, or of its allowed values
+	elemType := reflect.TypeOf(f.defaultValue)
+	if isList(elemType) {
+		elemType = elemType.Elem()
+	}
+
+	// elements accumulate in a new slice
+	if f.value == nil {
+		f.value = reflect.MakeSlice(reflect.SliceOf(elemType), 0, 0).Interface()
+	}
+	list := reflect.ValueOf(f.value)
+
+	for _, elem := range splitList(s, f.separator) {
+		converted, err := convert(elem, &f.Arg)
+		if err != nil {
+			return err
+		}
+		if converted == nil || reflect.TypeOf(converted) != elemType {
+			return BadArgument{Arg: &f.Arg, Message: fmt.Sprintf("illegal value %s, must be %v", elem, elemType)}
+		}
+		list = reflect.Append(list, reflect.ValueOf(converted))
+	}
+	f.value = list.Interface()
+
+	return nil
+}
+
+// setMapEntry converts the `key=value` entry `s` and adds it to the value of the flag.
+func (f *Flag) setMapEntry(s string) error {
+	// split the entry at the first `=`
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return BadArgument{Arg: &f.Arg, Message: fmt.Sprintf("illegal value %s, must be key=value", s)}
+	}
+	key, value := s[:i], s[i+1:]
+
+	converted, err := convert(value, &f.Arg)
+	if err != nil {
+		return err
+	}
+
+	// entries accumulate in a new map of the type of the default value
+	mapType := reflect.TypeOf(f.defaultValue)
+	if f.value == nil {
+		f.value = reflect.MakeMap(mapType).Interface()
+	}
+	entries := reflect.ValueOf(f.value)
+
+	mapKey := reflect.ValueOf(key).Convert(mapType.Key())
+	if entries.MapIndex(mapKey).IsValid() {
+		return BadArgument{Arg: &f.Arg, Message: fmt.Sprintf("duplicate key %s", key)}
+	}
+	if converted == nil || reflect.TypeOf(converted) != mapType.Elem() {
+		return BadArgument{Arg: &f.Arg, Message: fmt.Sprintf("illegal value %s, must be %v", value, mapType.Elem())}
+	}
+	entries.SetMapIndex(mapKey, reflect.ValueOf(converted))
+
+	return nil
 }
 
 /***********************************************
         PRIVATE FUNCTIONS AND VARIABLES
 ***********************************************/
 
-// format command-line argument values
-func formatCommandValues(values []string) (formatted []string) {
+// token is a formatted command-line value
+type token struct {
+	// flag (e.g. `--output` or `-o`) or argument value
+	value string
 
-	formatted = make([]string, 0)
+	// value attached to a flag (`--flag=value`, `-fvalue`)
+	attached    string
+	hasAttached bool
 
-	for _, presplit := range values {
-		for _, value := range detectSplitCombined(presplit) {
-			// split a value by `=`
-			if isFlag(value) {
-				parts := strings.Split(value, "=")
+	// if true, the value is not a flag, even if it starts with `-`: the value
+	// of the previous flag (e.g. `-2h` in `--since -2h`), a negative number or
+	// duration, or a value following `--`
+	literal bool
 
-				for _, part := range parts {
-					if strings.Trim(part, " ") != "" {
-						formatted = append(formatted, part)
-					}
-				}
-			} else {
-				formatted = append(formatted, value)
+	// position of the value in the command-line arguments
+	pos int
+}
+
+// format command-line argument values (`offset` is the position of the first value in the command-line arguments)
+func formatCommandValues(values []string, offset int, commandConfig *CommandConfig) (formatted []token) {
+
+	formatted = make([]token, 0)
+
+	// if true, the next value is the value of the previous flag, unless it is
+	// a registered flag
+	valueNext := false
+
+	for index, value := range values {
+		if !isFlag(value) || valueNext && !commandConfig.isRegisteredFlag(value) {
+			formatted = append(formatted, token{value: value, literal: valueNext, pos: offset + index})
+			valueNext = false
+			continue
+		}
+
+		// values following `--` are positional values
+		if value == "--" {
+			for i, rest := range values[index+1:] {
+				formatted = append(formatted, token{value: rest, literal: true, pos: offset + index + 1 + i})
+			}
+			return
+		}
+
+		// a negative number or duration (e.g. `-5` or `-2h`) is a positional
+		// value, unless it starts with a registered short flag
+		if isNegative(value) && !commandConfig.isRegisteredFlag(value) {
+			formatted = append(formatted, token{value: value, literal: true, pos: offset + index})
+			continue
+		}
+
+		// break apart combined short flags
+		if isShortCluster(value) {
+			for _, tok := range splitShortCluster(value, commandConfig) {
+				tok.pos = offset + index
+				formatted = append(formatted, tok)
 			}
+			valueNext = commandConfig.takesNextValue(formatted[len(formatted)-1])
+			continue
+		}
+
+		// split a value at the first `=`; the rest (possibly empty) is the attached value
+		tok := token{value: value, pos: offset + index}
+		if i := strings.Index(value, "="); i >= 0 {
+			tok.value, tok.attached, tok.hasAttached = value[:i], value[i+1:], true
 		}
+		formatted = append(formatted, tok)
+		valueNext = commandConfig.takesNextValue(tok)
 	}
-	fmt.Printf("formatted = %v\n", formatted)
 
 	return
 }
 
-// detectSplitCombined checks whether the argument is a combined flag and breaks
-// it apart if it is. E.g., for declared flags `-a` and `-b`, the provided
-// argument `-ab` will be broken in two.
-func detectSplitCombined(s string) []string {
-	// Don't try to process assignments
-	if strings.Contains(s, "=") {
-		return []string{s}
+// takesNextValue returns `true` if the flag `tok` takes the next command-line
+// argument as its value: it is a registered non-boolean flag without an
+// attached value, and its value is not optional.
+// If `commandConfig` is `nil`, no flag takes a value.
+func (commandConfig *CommandConfig) takesNextValue(tok token) bool {
+	if commandConfig == nil || tok.hasAttached {
+		return false
+	}
+
+	var flag *Flag
+	if isShortFlag(tok.value) {
+		flag = commandConfig.Flags[commandConfig.flagsShort[tok.value[1:]]]
+	} else if isFlag(tok.value) {
+		flag, _, _ = commandConfig.lookupLongFlag(tok.value)
+	}
+	if flag == nil {
+		return false
+	}
+
+	_, isBool := flag.defaultValue.(bool)
+	return !isBool && flag.kind != optionalValueFlag
+}
+
+// isRegisteredFlag returns `true` if `value` is a flag of the command: a long
+// flag matching a registered flag (or several, if abbreviated), or short flags
+// starting with a registered short flag.
+func (commandConfig *CommandConfig) isRegisteredFlag(value string) bool {
+	if commandConfig == nil {
+		return false
+	}
+	if i := strings.Index(value, "="); i >= 0 {
+		value = value[:i]
 	}
-	// If it's a long-form argument, then no split
-	if strings.HasPrefix(s, "--") {
-		return []string{s}
+
+	if strings.HasPrefix(value, "--") {
+		_, _, err := commandConfig.lookupLongFlag(value)
+		_, isUnknown := err.(UnknownFlag)
+		return !isUnknown
 	}
-	if strings.HasPrefix(s, "-") {
-		parts := strings.Split(s, "")
-		for i, p := range parts {
-			if i == 0 {
-				continue
+
+	_, ok := commandConfig.flagsShort[string([]rune(value)[1:2])]
+	return ok
+}
+
+// splitShortCluster breaks apart a cluster of short flags, as POSIX getopt
+// does. E.g., for declared boolean flags `-a` and `-b`, the provided argument
+// `-ab` will be broken in two. When a flag in the cluster takes a value, the
+// rest of the cluster (without a leading `=`) is attached as its value, so
+// `-ofile`, `-o=file` and `-vofile` all give `file` to `-o`, and `-o=` gives
+// it an empty value.
+// If `commandConfig` is `nil`, every flag is assumed not to take a value.
+func splitShortCluster(s string, commandConfig *CommandConfig) []token {
+	tokens := make([]token, 0)
+
+	chars := []rune(s[1:])
+	for i, c := range chars {
+
+		// `-ab=value` attaches the value to the last flag of the cluster
+		if c == '=' && len(tokens) > 0 {
+			tokens[len(tokens)-1].attached, tokens[len(tokens)-1].hasAttached = string(chars[i+1:]), true
+			return tokens
+		}
+
+		tok := token{value: "-" + string(c)}
+
+		if commandConfig != nil && commandConfig.shortFlagTakesValue(string(c)) {
+			if rest := string(chars[i+1:]); rest != "" {
+				tok.attached, tok.hasAttached = strings.TrimPrefix(rest, "="), true
 			}
-			parts[i] = "-" + p
+			return append(tokens, tok)
 		}
-		return parts[1:]
+
+		tokens = append(tokens, tok)
 	}
-	return []string{s}
+
+	return tokens
+}
+
+// check if value is a cluster of one or more short flags (e.g. `-abc`, `-ofile`)
+func isShortCluster(value string) bool {
+	return isFlag(value) && !strings.HasPrefix(value, "--") && len(value) > 2
+}
+
+// splitList splits `s` at every `separator` not escaped with a backslash
+func splitList(s string, separator string) []string {
+	parts := make([]string, 0)
+
+	var part strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			part.WriteByte(s[i])
+		case strings.HasPrefix(s[i:], separator):
+			parts = append(parts, part.String())
+			part.Reset()
+			i += len(separator) - 1
+		default:
+			part.WriteByte(s[i])
+		}
+	}
+
+	return append(parts, part.String())
 }
 
 // check if value is a flag
@@ -685,6 +2265,12 @@ func isFlag(value string) bool {
 	return len(value) >= 2 && strings.HasPrefix(value, "-")
 }
 
+// check if value looks like a negative number or duration, e.g. `-5`, `-.5` or `-2h`
+func isNegative(value string) bool {
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "-"), ".")
+	return strings.HasPrefix(value, "-") && digits != "" && digits[0] >= '0' && digits[0] <= '9'
+}
+
 // check if value is a short flag
 func isShortFlag(value string) bool {
 	return isFlag(value) && len(value) == 2 && !strings.HasPrefix(value, "--")
@@ -718,10 +2304,10 @@ func isVariadicArgument(value string) (bool, string) {
 }
 
 // check if values corresponds to the root command
-func isRootCommand(values []string, registry Registry) bool {
+func isRootCommand(values []string, registry *Registry) bool {
 
 	// FALSE: if the root command is not registered
-	if _, ok := registry[""]; !ok {
+	if _, ok := registry.commands[""]; !ok {
 		return false
 	}
 
@@ -731,11 +2317,11 @@ func isRootCommand(values []string, registry Registry) bool {
 	}
 
 	// get root `CommandConfig` value from the registry
-	rootCommandConfig := registry[""]
+	rootCommandConfig := registry.commands[""]
 
 	// TRUE: if the first value is not a registered command
 	// and some arguments are registered for the root command
-	if _, ok := registry[values[0]]; len(rootCommandConfig.Args) > 0 && !ok {
+	if _, ok := registry.commands[values[0]]; len(rootCommandConfig.Args) > 0 && !ok {
 		return true
 	}
 
@@ -761,6 +2347,35 @@ func nextValue(slice []string) (v string, newSlice []string) {
 	return
 }
 
+// decimalLiteral strips the leading zeros of a decimal integer literal, and
+// the `_` separators following them, so that `010` and `0_10` are read as ten
+// rather than as legacy octal literals (`0o10` is octal). Other literals are
+// unchanged, so a base prefix after leading zeros (`00x1f`) remains illegal.
+func decimalLiteral(value string) string {
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+	if strings.Trim(value, "0123456789_") != "" {
+		return sign + value
+	}
+	for len(value) > 1 && value[0] == '0' && (value[1] >= '0' && value[1] <= '9' || value[1] == '_') {
+		value = value[1:]
+		if len(value) > 1 && value[0] == '_' {
+			value = value[1:]
+		}
+	}
+	return sign + value
+}
+
+// format a single value for display
+func formatValue(value interface{}) string {
+	if v, ok := value.(Value); ok {
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
 // trim whitespaces from a value
 func trimWhitespaces(value string) string {
 	return strings.Trim(value, "")
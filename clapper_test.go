@@ -2,6 +2,9 @@ package clapper
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -16,7 +19,7 @@ var tests []struct {
 	defaultVal interface{}
 }
 
-func setup(t *testing.T, args []string) (*CommandConfig, error) {
+func setup(t *testing.T, args []string) (*ParseResult, error) {
 	tests = []struct {
 		subCommand string
 		arg        string
@@ -390,3 +393,894 @@ func TestCombinedFlags(t *testing.T) {
 		}
 	}
 }
+
+// test getopt_long style abbreviated long flags
+func TestAbbreviatedFlags(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected map[string]interface{}
+		err      error
+	}{
+		{[]string{"--verb"}, map[string]interface{}{"verbose": true}, nil},
+		{[]string{"--vers", "2.0.0"}, map[string]interface{}{"version": "2.0.0"}, nil},
+		{[]string{"--no-c"}, map[string]interface{}{"clean": false}, nil},
+		{[]string{"--clean"}, map[string]interface{}{"clean": true}, nil},
+		{[]string{"--ver"}, nil, AmbiguousFlag{}},
+		{[]string{"--dump"}, nil, UnknownFlag{}},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AbbreviateFlags = true
+		root.AddFlag("verbose", "v", false)
+		root.AddFlag("version", "V", "")
+		root.AddFlag("no-clean", "", true)
+
+		cmd, err := reg.Parse(test.args)
+		if test.err != nil {
+			assertError(t, err, "%v", test.args)
+			assertEqual(t, fmt.Sprintf("%T", test.err), fmt.Sprintf("%T", err), "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		for k, v := range test.expected {
+			assertEqual(t, v, cmd.Flags[k].value, " (%s)", k)
+		}
+	}
+
+	// ambiguous matches are listed in the error
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AbbreviateFlags = true
+	root.AddFlag("verbose", "v", false)
+	root.AddFlag("version", "V", "")
+	_, err := reg.Parse([]string{"--ver"})
+	assertEqual(t, AmbiguousFlag{"--ver", []string{"--verbose", "--version"}}, err)
+
+	// abbreviations are opt-in
+	root.AbbreviateFlags = false
+	_, err = reg.Parse([]string{"--verb"})
+	assertEqual(t, UnknownFlag{"--verb"}, err)
+}
+
+// test flags with an optional value (`--color[=WHEN]`)
+func TestOptionalValueFlags(t *testing.T) {
+	tests := []struct {
+		args     []string
+		color    interface{}
+		depth    interface{}
+		argument interface{}
+	}{
+		{[]string{}, nil, nil, nil},
+		{[]string{"--color"}, "auto", nil, nil},
+		{[]string{"--color=always"}, "always", nil, nil},
+		{[]string{"-c=never"}, "never", nil, nil},
+		{[]string{"-c", "never"}, "auto", nil, "never"},
+		{[]string{"--color", "file.txt", "--depth"}, "auto", 1, "file.txt"},
+		{[]string{"--depth=3", "--color"}, "auto", 3, nil},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddArg("file", "")
+		_, err := root.AddOptionalFlag("color", "c", []string{"auto", "always", "never"}, "auto")
+		assertNoError(t, err)
+		_, err = root.AddOptionalFlag("depth", "", 0, 1)
+		assertNoError(t, err)
+
+		cmd, err := reg.Parse(test.args)
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.color, cmd.Flags["color"].value, " %v (color)", test.args)
+		assertEqual(t, test.depth, cmd.Flags["depth"].value, " %v (depth)", test.args)
+		assertEqual(t, test.argument, cmd.Args["file"].value, " %v (file)", test.args)
+	}
+
+	// attached values are still validated
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddOptionalFlag("color", "c", []string{"auto", "always", "never"}, "auto")
+	_, err := reg.Parse([]string{"--color=sometimes"})
+	assertError(t, err)
+
+	// invalid registrations
+	_, err = root.AddOptionalFlag("force", "f", false, true)
+	assertError(t, err, "boolean optional flag")
+	_, err = root.AddOptionalFlag("level", "l", 0, "high")
+	assertError(t, err, "implicit value of the wrong type")
+}
+
+// test short flags with attached values (`-ofile`, `-I/usr/include`)
+func TestAttachedShortFlagValues(t *testing.T) {
+	tests := []struct {
+		args    []string
+		verbose interface{}
+		output  interface{}
+		include interface{}
+		file    interface{}
+	}{
+		{[]string{"-vo", "out.txt"}, true, "out.txt", nil, nil},
+		{[]string{"-voout.txt"}, true, "out.txt", nil, nil},
+		{[]string{"-ofile", "-v"}, true, "file", nil, nil},
+		{[]string{"-o=file"}, nil, "file", nil, nil},
+		{[]string{"-I/usr/include", "main.c"}, nil, nil, "/usr/include", "main.c"},
+		{[]string{"-vI", "/usr/include"}, true, nil, "/usr/include", nil},
+		{[]string{"-v=main.c"}, true, nil, nil, "main.c"},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddArg("file", "")
+		root.AddFlag("verbose", "v", false)
+		root.AddFlag("output", "o", "")
+		root.AddFlag("include", "I", "")
+
+		cmd, err := reg.Parse(test.args)
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.verbose, cmd.Flags["verbose"].value, " %v (verbose)", test.args)
+		assertEqual(t, test.output, cmd.Flags["output"].value, " %v (output)", test.args)
+		assertEqual(t, test.include, cmd.Flags["include"].value, " %v (include)", test.args)
+		assertEqual(t, test.file, cmd.Args["file"].value, " %v (file)", test.args)
+	}
+}
+
+// test that only the first `=` separates a flag from its value
+func TestFlagValuesWithEquals(t *testing.T) {
+	tests := []struct {
+		args   []string
+		filter interface{}
+		name   interface{}
+		err    bool
+	}{
+		{[]string{"--filter=a=b"}, "a=b", nil, false},
+		{[]string{"--filter=KEY=VAL="}, "KEY=VAL=", nil, false},
+		{[]string{"-f=a=b"}, "a=b", nil, false},
+		{[]string{"-fa=b"}, "a=b", nil, false},
+		{[]string{"--name="}, nil, "", false},
+		{[]string{"-n="}, nil, "", false},
+		{[]string{"--name=", "--filter="}, "", "", false},
+		{[]string{"--name"}, nil, nil, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("filter", "f", "")
+		root.AddFlag("name", "n", "")
+
+		cmd, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.filter, cmd.Flags["filter"].value, " %v (filter)", test.args)
+		assertEqual(t, test.name, cmd.Flags["name"].value, " %v (name)", test.args)
+	}
+}
+
+// test map flags (`--label key=value`)
+func TestMapFlags(t *testing.T) {
+	tests := []struct {
+		args   []string
+		labels map[string]string
+		limits map[string]interface{}
+		err    bool
+	}{
+		{[]string{}, map[string]string{"app": "default"}, map[string]interface{}{}, false},
+		{[]string{"--label", "app=web", "-l", "tier=db"}, map[string]string{"app": "web", "tier": "db"}, map[string]interface{}{}, false},
+		{[]string{"--label=env=KEY=VAL", "-lempty="}, map[string]string{"env": "KEY=VAL", "empty": ""}, map[string]interface{}{}, false},
+		{[]string{"--limit", "cpu=2", "--limit=mem=512"}, map[string]string{"app": "default"}, map[string]interface{}{"cpu": 2, "mem": 512}, false},
+		{[]string{"--label", "app=web", "--label", "app=db"}, nil, nil, true},
+		{[]string{"--label", "app"}, nil, nil, true},
+		{[]string{"--limit", "cpu=two"}, nil, nil, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		_, err := root.AddFlag("label", "l", map[string]string{"app": "default"})
+		assertNoError(t, err)
+		_, err = root.AddFlag("limit", "", map[string]int{})
+		assertNoError(t, err)
+
+		cmd, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.labels, cmd.Flags["label"].AsStringMap(), " %v (label)", test.args)
+		assertEqual(t, test.limits, cmd.Flags["limit"].AsMap(), " %v (limit)", test.args)
+	}
+
+	// map flags need string keys
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	_, err := root.AddFlag("ports", "", map[int]string{})
+	assertError(t, err)
+}
+
+// test list flags (`--hosts a,b,c`)
+func TestListFlags(t *testing.T) {
+	tests := []struct {
+		args  []string
+		hosts []string
+		ports []int
+		modes []string
+		err   bool
+	}{
+		{[]string{}, nil, nil, nil, false},
+		{[]string{"--hosts", "a,b,c"}, []string{"a", "b", "c"}, nil, nil, false},
+		{[]string{"--hosts=a,b", "-h", "c"}, []string{"a", "b", "c"}, nil, nil, false},
+		{[]string{"--hosts", `a\,b,c\\`}, []string{"a,b", `c\`}, nil, nil, false},
+		{[]string{"--hosts="}, []string{""}, nil, nil, false},
+		{[]string{"--ports", "80:443", "-p8080"}, nil, []int{80, 443, 8080}, nil, false},
+		{[]string{"--modes", "read,write"}, nil, nil, []string{"read", "write"}, false},
+		{[]string{"--modes", "read,delete"}, nil, nil, nil, true},
+		{[]string{"--ports", "80:http"}, nil, nil, nil, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		_, err := root.AddListFlag("hosts", "h", "", "")
+		assertNoError(t, err)
+		_, err = root.AddListFlag("ports", "p", 0, ":")
+		assertNoError(t, err)
+		_, err = root.AddListFlag("modes", "", []string{"read", "write"}, ",")
+		assertNoError(t, err)
+
+		cmd, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.hosts, cmd.Flags["hosts"].AsStrings(), " %v (hosts)", test.args)
+		assertEqual(t, test.ports, cmd.Flags["ports"].AsInts(), " %v (ports)", test.args)
+		if test.modes != nil {
+			assertEqual(t, test.modes, cmd.Flags["modes"].AsStrings(), " %v (modes)", test.args)
+		}
+	}
+
+	// boolean flags can not be lists
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	_, err := root.AddListFlag("force", "f", false, "")
+	assertError(t, err)
+}
+
+// test that parsing doesn't leak values between calls
+func TestRepeatedParse(t *testing.T) {
+	reg := NewRegistry()
+	info, _ := reg.Register("info")
+	info.AddArg("category", "")
+	info.AddArg("subjects...", "")
+	info.AddFlag("verbose", "v", false)
+	info.AddFlag("label", "l", map[string]string{})
+	info.AddListFlag("hosts", "", "", ",")
+
+	first, err := reg.Parse([]string{"info", "student", "math", "-v", "-l", "a=b", "--hosts", "x,y"})
+	assertNoError(t, err)
+	second, err := reg.Parse([]string{"info", "manager", "-l", "a=c"})
+	assertNoError(t, err)
+
+	assertEqual(t, "student", first.Args["category"].value)
+	assertEqual(t, []string{"math"}, first.Args["subjects"].value)
+	assertEqual(t, true, first.Flags["verbose"].value)
+	assertEqual(t, map[string]string{"a": "b"}, first.Flags["label"].value)
+	assertEqual(t, []string{"x", "y"}, first.Flags["hosts"].value)
+
+	assertEqual(t, "manager", second.Args["category"].value)
+	assertEqual(t, nil, second.Args["subjects"].value)
+	assertEqual(t, nil, second.Flags["verbose"].value)
+	assertEqual(t, map[string]string{"a": "c"}, second.Flags["label"].value)
+	assertEqual(t, nil, second.Flags["hosts"].value)
+
+	// the registered configuration holds no values
+	assertEqual(t, info, second.Command)
+	assertEqual(t, nil, info.Args["category"].value)
+	assertEqual(t, nil, info.Flags["verbose"].value)
+}
+
+// test that a frozen registry can't be modified
+func TestFreeze(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("output", "")
+	_, err := root.AddFlag("verbose", "v", false)
+	assertNoError(t, err)
+
+	assertNoError(t, reg.Freeze())
+	assertNoError(t, reg.Freeze(), "freezing twice")
+
+	_, err = root.AddFlag("force", "f", false)
+	assertEqual(t, ErrFrozen, err)
+	_, err = root.AddOptionalFlag("color", "", "", "auto")
+	assertEqual(t, ErrFrozen, err)
+	_, err = root.AddListFlag("hosts", "", "", ",")
+	assertEqual(t, ErrFrozen, err)
+
+	// commands and arguments are not registered, and the mistakes are recorded
+	info, ok := reg.Register("info")
+	assertEqual(t, false, ok)
+	assertEqual(t, (*CommandConfig)(nil), reg.Command("info"))
+	_, err = info.AddFlag("verbose", "v", false)
+	assertEqual(t, ErrFrozen, err)
+	root.AddArg("input", "")
+	assertEqual(t, []string{"output"}, root.ArgNames)
+	_, ok = reg.Register("")
+	assertEqual(t, true, ok)
+	root.AddArg("output", "")
+
+	err = reg.Validate()
+	assertEqual(t, SchemaErrors{
+		{Command: "info", Message: ErrFrozen.Error()},
+		{Command: "", Flag: "force", Message: ErrFrozen.Error()},
+		{Command: "", Flag: "color", Message: ErrFrozen.Error()},
+		{Command: "", Flag: "hosts", Message: ErrFrozen.Error()},
+		{Command: "", Arg: "input", Message: ErrFrozen.Error()},
+	}, err)
+	assertEqual(t, err, reg.Freeze(), "freezing again")
+
+	// a frozen registry still parses
+	cmd, err := reg.Parse([]string{"-v", "out.txt"})
+	assertNoError(t, err)
+	assertEqual(t, "out.txt", cmd.Args["output"].value)
+	assertEqual(t, root, reg.Command(""))
+
+	// an invalid registry can't be frozen
+	reg = NewRegistry()
+	info, _ = reg.Register("info")
+	info.AddArg("subjects...", "")
+	info.AddArg("username", "").Optional()
+	assertError(t, reg.Freeze())
+	info.AddArg("category", "")
+}
+
+// test parsing from many goroutines (run with `go test -race`)
+func TestConcurrentParse(t *testing.T) {
+	reg := NewRegistry()
+	info, _ := reg.Register("info")
+	info.AddArg("category", "")
+	info.AddArg("subjects...", "")
+	info.AddFlag("verbose", "v", false)
+	info.AddFlag("label", "l", map[string]string{})
+	info.AddListFlag("hosts", "", "", ",")
+	assertNoError(t, reg.Freeze())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			category := fmt.Sprintf("c%d", i)
+			label := fmt.Sprintf("k=%d", i)
+			cmd, err := reg.Parse([]string{"info", category, "s1", "s2", "-v", "-l", label, "--hosts", category})
+			switch {
+			case err != nil:
+				errs <- err
+			case cmd.Args["category"].AsString() != category:
+				errs <- fmt.Errorf("expected category %s, got %s", category, cmd.Args["category"].AsString())
+			case cmd.Flags["label"].AsStringMap()["k"] != fmt.Sprint(i):
+				errs <- fmt.Errorf("expected label %d, got %v", i, cmd.Flags["label"].AsStringMap())
+			case !reflect.DeepEqual(cmd.Flags["hosts"].AsStrings(), []string{category}):
+				errs <- fmt.Errorf("expected hosts [%s], got %v", category, cmd.Flags["hosts"].AsStrings())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// test registering commands while parsing
+func TestConcurrentRegister(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddFlag("verbose", "v", false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			cmd, _ := reg.Register(fmt.Sprintf("cmd%d", i))
+			cmd.AddFlag("force", "f", false)
+			root.AddFlag(fmt.Sprintf("flag%d", i), "", "")
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := reg.Parse([]string{"-v"})
+			assertNoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		assertNotNil(t, reg.Command(fmt.Sprintf("cmd%d", i)))
+	}
+}
+
+// test tracking of provided arguments and flags
+func TestProvidedValues(t *testing.T) {
+	cmd, err := setup(t, []string{"info", "student", "-vo", "./dir", "--verbose", "math", "--version=2.0.0", "science"})
+	assertNoError(t, err)
+
+	tests := []struct {
+		param     *Arg
+		isSet     bool
+		positions []int
+	}{
+		{cmd.Args["category"], true, []int{1}},
+		{cmd.Args["username"], true, []int{5}},
+		{cmd.Args["subjects"], true, []int{7}},
+		{&cmd.Flags["verbose"].Arg, true, []int{2, 4}},
+		{&cmd.Flags["output"].Arg, true, []int{2}},
+		{&cmd.Flags["version"].Arg, true, []int{6}},
+		{&cmd.Flags["clean"].Arg, false, []int{}},
+	}
+	for _, test := range tests {
+		assertEqual(t, test.isSet, test.param.IsSet(), " (%s)", test.param.Name)
+		assertEqual(t, len(test.positions), test.param.Occurrences(), " (%s)", test.param.Name)
+		if len(test.positions) > 0 {
+			assertEqual(t, test.positions, test.param.Positions(), " (%s)", test.param.Name)
+		}
+	}
+
+	// a default value is not a provided value
+	assertEqual(t, "1.0.1", cmd.Flags["version"].Arg.defaultValue)
+	cmd, err = setup(t, []string{"info", "student"})
+	assertNoError(t, err)
+	assertEqual(t, false, cmd.Flags["version"].IsSet())
+
+	// only provided flags are visited
+	cmd, err = setup(t, []string{"info", "student", "--verbose", "-o", "./dir", "--no-clean"})
+	assertNoError(t, err)
+	visited := make([]string, 0)
+	cmd.Visit(func(f *Flag) {
+		visited = append(visited, f.Name)
+	})
+	assertEqual(t, []string{"clean", "output", "verbose"}, visited)
+}
+
+// test accessors returning errors
+func TestAccessorErrors(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("files...", "")
+	root.AddFlag("port", "p", 8080)
+	root.AddFlag("name", "n", "")
+	root.AddFlag("mode", "m", []string{"fast", "slow"})
+	root.AddFlag("timeout", "t", time.Second)
+
+	cmd, err := reg.Parse([]string{"--name", "web", "a.txt", "b.txt"})
+	assertNoError(t, err)
+
+	// values and default values
+	port, err := cmd.Flags["port"].AsIntE()
+	assertNoError(t, err)
+	assertEqual(t, 8080, port)
+	name, err := cmd.Flags["name"].AsStringE()
+	assertNoError(t, err)
+	assertEqual(t, "web", name)
+	files, err := cmd.Args["files"].AsStringsE()
+	assertNoError(t, err)
+	assertEqual(t, []string{"a.txt", "b.txt"}, files)
+	assertEqual(t, time.Second, cmd.Flags["timeout"].MustDuration())
+
+	// type mismatches
+	_, err = cmd.Flags["name"].AsIntE()
+	assertEqual(t, fmt.Sprintf("%T", WrongType{}), fmt.Sprintf("%T", err))
+	_, err = cmd.Flags["port"].AsStringE()
+	assertEqual(t, fmt.Sprintf("%T", WrongType{}), fmt.Sprintf("%T", err))
+	_, err = cmd.Flags["timeout"].AsIntE()
+	assertError(t, err, "a duration is not an int")
+
+	// missing values
+	_, err = cmd.Flags["mode"].AsStringE()
+	assertEqual(t, fmt.Sprintf("%T", MissingValue{}), fmt.Sprintf("%T", err))
+	cmd, err = reg.Parse([]string{})
+	assertNoError(t, err)
+	_, err = cmd.Args["files"].AsStringsE()
+	assertEqual(t, fmt.Sprintf("%T", MissingValue{}), fmt.Sprintf("%T", err))
+
+	// generic accessor
+	var timeout time.Duration
+	assertNoError(t, cmd.Flags["timeout"].Get(&timeout))
+	assertEqual(t, time.Second, timeout)
+	var mode int
+	assertError(t, cmd.Flags["name"].Get(&mode))
+	assertError(t, cmd.Flags["name"].Get(mode), "not a pointer")
+
+	// must variants panic
+	func() {
+		defer func() {
+			_, ok := recover().(WrongType)
+			assertEqual(t, true, ok, "MustInt should panic with a WrongType error")
+		}()
+		cmd.Flags["name"].MustInt()
+	}()
+	func() {
+		defer func() {
+			_, ok := recover().(MissingValue)
+			assertEqual(t, true, ok, "MustGet should panic with a MissingValue error")
+		}()
+		var mode string
+		cmd.Flags["mode"].MustGet(&mode)
+	}()
+}
+
+// logLevel is a custom `Value` type
+type logLevel struct {
+	names []string
+	level int
+}
+
+func (l *logLevel) Set(s string) error {
+	for i, name := range l.names {
+		if name == s {
+			l.level = i
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %s", s)
+}
+
+func (l *logLevel) String() string {
+	if l.level < len(l.names) {
+		return l.names[l.level]
+	}
+	return fmt.Sprint(l.level)
+}
+
+func (l *logLevel) Type() string {
+	return "level"
+}
+
+// test custom value types
+func TestCustomValues(t *testing.T) {
+	names := []string{"debug", "info", "warn"}
+
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("levels...", &logLevel{names: names})
+	level, err := root.AddFlag("level", "l", &logLevel{names: names, level: 1})
+	assertNoError(t, err)
+	_, err = root.AddFlag("max", "", []*logLevel{{names: names, level: 1}, {names: names, level: 2}})
+	assertNoError(t, err)
+
+	cmd, err := reg.Parse([]string{"--level", "warn", "debug", "info", "--max=info"})
+	assertNoError(t, err)
+	assertEqual(t, "warn", cmd.Flags["level"].AsValue().String())
+	assertEqual(t, "info", cmd.Flags["max"].AsValue().String())
+	levels := cmd.Args["levels"].value.([]*logLevel)
+	assertEqual(t, 2, len(levels))
+	assertEqual(t, "debug", levels[0].String())
+	assertEqual(t, "info", levels[1].String())
+
+	// the default value is not modified
+	assertEqual(t, "info", level.AsValue().String())
+	cmd, err = reg.Parse([]string{})
+	assertNoError(t, err)
+	assertEqual(t, "info", cmd.Flags["level"].AsValue().String())
+
+	// conversion and allowed values errors
+	_, err = reg.Parse([]string{"--level", "trace"})
+	assertError(t, err)
+	_, err = reg.Parse([]string{"--max", "debug"})
+	assertError(t, err)
+
+	// help text
+	assertEqual(t, "level", level.TypeName())
+	assertEqual(t, "info", level.DefaultString())
+	assertEqual(t, "level", cmd.Flags["max"].TypeName())
+	assertEqual(t, "info|warn", cmd.Flags["max"].DefaultString())
+	assertEqual(t, "int", (&Arg{defaultValue: 1}).TypeName())
+	assertEqual(t, "a|b", (&Arg{defaultValue: []string{"a", "b"}}).DefaultString())
+}
+
+// test the integer types
+func TestIntegerTypes(t *testing.T) {
+	tests := []struct {
+		defaultValue interface{}
+		value        string
+		expected     interface{}
+		err          bool
+	}{
+		{0, "42", 42, false},
+		{0, "010", 10, false},
+		{0, "0_10", 10, false},
+		{0, "-00_1_0", -10, false},
+		{0, "0__1", nil, true},
+		{0, "0_", nil, true},
+		{0, "-0x1f", -31, false},
+		{0, "00x1f", nil, true},
+		{0, "-00b1", nil, true},
+		{0, "0010_0", 100, false},
+		{0, "1_000_000", 1000000, false},
+		{int8(0), "127", int8(127), false},
+		{int8(0), "128", nil, true},
+		{int8(0), "-128", int8(-128), false},
+		{int16(0), "0o777", int16(511), false},
+		{int32(0), "0b1010", int32(10), false},
+		{int64(0), "9223372036854775807", int64(9223372036854775807), false},
+		{int64(0), "9223372036854775808", nil, true},
+		{uint(0), "7", uint(7), false},
+		{uint8(0), "255", uint8(255), false},
+		{uint8(0), "256", nil, true},
+		{uint8(0), "-1", nil, true},
+		{uint16(0), "65535", uint16(65535), false},
+		{uint32(0), "0xffff_ffff", uint32(0xffffffff), false},
+		{uint64(0), "18446744073709551615", uint64(18446744073709551615), false},
+		{uint64(0), "1e3", nil, true},
+		{[]uint16{80, 443}, "0x50", uint16(80), false},
+		{[]uint16{80, 443}, "8080", nil, true},
+		{time.Duration(0), "1m30s", 90 * time.Second, false},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("number", "n", test.defaultValue)
+
+		cmd, err := reg.Parse([]string{"--number=" + test.value})
+		if test.err {
+			assertError(t, err, "%T %s", test.defaultValue, test.value)
+			continue
+		}
+		assertNoError(t, err, "%T %s", test.defaultValue, test.value)
+		assertEqual(t, test.expected, cmd.Flags["number"].value, " (%T %s)", test.defaultValue, test.value)
+	}
+
+	// accessors
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("ids...", uint64(0))
+	root.AddFlag("port", "p", uint16(8080))
+	root.AddFlag("pid", "", int32(0))
+	cmd, err := reg.Parse([]string{"--pid", "1234", "1", "0xffffffffffffffff"})
+	assertNoError(t, err)
+	assertEqual(t, uint16(8080), cmd.Flags["port"].AsUint16())
+	assertEqual(t, int32(1234), cmd.Flags["pid"].AsInt32())
+	assertEqual(t, []uint64{1, 18446744073709551615}, cmd.Args["ids"].AsUint64s())
+	assertEqual(t, int64(0), cmd.Flags["pid"].AsInt64())
+
+	// negative positional values are not flags, unless they are registered short flags
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddFlag("one", "1", false)
+	root.AddArg("offset", 0)
+	root.AddArg("scale", int8(0))
+	cmd, err = reg.Parse([]string{"-5", "-1"})
+	assertNoError(t, err)
+	assertEqual(t, -5, cmd.Args["offset"].AsInt())
+	assertEqual(t, true, cmd.Flags["one"].AsBool())
+	assertEqual(t, false, cmd.Args["scale"].IsSet())
+	cmd, err = reg.Parse([]string{"--", "-5", "-1"})
+	assertNoError(t, err)
+	assertEqual(t, int8(-1), cmd.Args["scale"].AsInt8())
+	assertEqual(t, false, cmd.Flags["one"].IsSet())
+	_, err = reg.Parse([]string{"-x5"})
+	assertError(t, err)
+}
+
+// test positional values beyond the registered arguments
+func TestStrictArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		strict   bool
+		expected []string
+		position int
+		missing  string
+	}{
+		{[]string{"ghost"}, true, nil, 0, ""},
+		{[]string{"ghost", "thatisuday", "extra"}, false, nil, 0, ""},
+		{[]string{"ghost", "thatisuday", "extra"}, true, nil, 1, ""},
+		{[]string{"ghost", "-v", "extra"}, true, nil, 2, ""},
+		{[]string{"copy", "a"}, true, nil, 0, "destination"},
+		{[]string{"copy", "a", "b"}, true, []string{"a", "b"}, 0, ""},
+		{[]string{"copy", "a", "-v", "b", "c"}, false, []string{"a", "b"}, 0, ""},
+		{[]string{"copy", "a", "-v", "b", "c"}, true, nil, 4, ""},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		ghost, _ := reg.Register("ghost")
+		ghost.StrictArgs = test.strict
+		ghost.AddFlag("verbose", "v", false)
+		cp, _ := reg.Register("copy")
+		cp.StrictArgs = test.strict
+		cp.AddArg("source", "")
+		cp.AddArg("destination", "")
+		cp.AddFlag("verbose", "v", false)
+
+		cmd, err := reg.Parse(test.args)
+		if test.position > 0 {
+			assertEqual(t, UnexpectedArgument{test.args[test.position], test.position}, err, " (%v)", test.args)
+			continue
+		}
+		if test.missing != "" {
+			assertError(t, err, "%v", test.args)
+			if e, ok := err.(BadArgument); ok {
+				assertEqual(t, test.missing, e.Arg.Name, " (%v)", test.args)
+			} else {
+				t.Errorf("expected a BadArgument error, got %#v (%v)", err, test.args)
+			}
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		if test.expected != nil {
+			assertEqual(t, test.expected[0], cmd.Args["source"].AsString(), " %v (source)", test.args)
+			assertEqual(t, test.expected[1], cmd.Args["destination"].AsString(), " %v (destination)", test.args)
+		}
+	}
+
+	// variadic arguments take all remaining values, within their counts
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.StrictArgs = true
+	root.AddArg("category", "")
+	root.AddArg("subjects...", "").WithCount(1, 2)
+	cmd, err := reg.Parse([]string{"student", "math", "science"})
+	assertNoError(t, err)
+	assertEqual(t, "student", cmd.Args["category"].AsString())
+	assertEqual(t, []string{"math", "science"}, cmd.Args["subjects"].AsStrings())
+	assertEqual(t, []int{1, 2}, cmd.Args["subjects"].Positions())
+	_, err = reg.Parse([]string{"student"})
+	assertError(t, err)
+	_, err = reg.Parse([]string{"student", "math", "science", "physics"})
+	assertError(t, err)
+}
+
+// test that strict arguments require the values of the required arguments
+func TestStrictMissingArgs(t *testing.T) {
+	reg := NewRegistry()
+	cp, _ := reg.Register("copy")
+	cp.AddArg("sources...", "")
+	cp.AddArg("destination", "")
+	mv, _ := reg.Register("move")
+	mv.StrictArgs = true
+	mv.AddArg("source", "")
+	mv.AddArg("mode", "").Optional()
+	mv.AddArg("destinations...", "")
+	mv.AddArg("target", "")
+
+	// optional and variadic arguments can miss values
+	cmd, err := reg.Parse([]string{"move", "a", "b"})
+	assertNoError(t, err)
+	assertEqual(t, "a", cmd.Args["source"].AsString())
+	assertEqual(t, "b", cmd.Args["target"].AsString())
+	assertEqual(t, false, cmd.Args["mode"].IsSet())
+	assertEqual(t, false, cmd.Args["destinations"].IsSet())
+
+	// required arguments can't
+	_, err = reg.Parse([]string{"move", "a"})
+	assertError(t, err)
+	if e, ok := err.(BadArgument); ok {
+		assertEqual(t, "target", e.Arg.Name)
+		assertEqual(t, -1, e.Position)
+		assertEqual(t, "target requires a value, none was provided", e.Error())
+	} else {
+		t.Errorf("expected a BadArgument error, got %#v", err)
+	}
+	_, err = reg.Parse([]string{"move"})
+	assertError(t, err)
+
+	// unless the arguments are not strict
+	cmd, err = reg.Parse([]string{"copy"})
+	assertNoError(t, err)
+	assertEqual(t, false, cmd.Args["destination"].IsSet())
+}
+
+// test matching positional values with optional and variadic arguments
+func TestPositionalLayouts(t *testing.T) {
+	tests := []struct {
+		args    []string
+		sources []string
+		dest    string
+		strict  bool
+		err     bool
+	}{
+		{[]string{"a", "b"}, []string{"a"}, "b", true, false},
+		{[]string{"a", "b", "c"}, []string{"a", "b"}, "c", true, false},
+		{[]string{"a", "-v", "b", "c", "d"}, []string{"a", "b", "c"}, "d", true, false},
+		{[]string{"a"}, []string{"a"}, "", false, false},
+		{[]string{"a"}, nil, "", true, true},
+		{[]string{}, nil, "", false, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.StrictArgs = test.strict
+		root.AddArg("sources...", "").WithCount(1, -1)
+		root.AddArg("dest", "")
+		root.AddFlag("verbose", "v", false)
+		assertNoError(t, reg.Freeze())
+
+		cmd, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.sources, cmd.Args["sources"].AsStrings(), " %v (sources)", test.args)
+		assertEqual(t, test.dest, cmd.Args["dest"].AsString(), " %v (dest)", test.args)
+	}
+
+	// optional arguments take surplus values, in order
+	layouts := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"a", "b"}, []string{"a", "", "", "b"}},
+		{[]string{"a", "b", "c"}, []string{"a", "b", "", "c"}},
+		{[]string{"a", "b", "c", "d"}, []string{"a", "b", "c", "d"}},
+	}
+	for _, layout := range layouts {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.StrictArgs = true
+		root.AddArg("first", "")
+		root.AddArg("second", "").Optional()
+		root.AddArg("third", "").Optional()
+		root.AddArg("last", "")
+		assertNoError(t, reg.Freeze())
+
+		cmd, err := reg.Parse(layout.args)
+		assertNoError(t, err, "%v", layout.args)
+		values := []string{}
+		for _, name := range []string{"first", "second", "third", "last"} {
+			values = append(values, cmd.Args[name].AsString())
+		}
+		assertEqual(t, layout.expected, values, " (%v)", layout.args)
+	}
+
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.StrictArgs = true
+	root.AddArg("first", "")
+	root.AddArg("second", "").Optional()
+	root.AddArg("last", "")
+	_, err := reg.Parse([]string{"a", "b", "c", "d"})
+	assertEqual(t, UnexpectedArgument{"d", 3}, err)
+
+	// optional arguments before a variadic argument are filled first
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddArg("mode", "").Optional()
+	root.AddArg("files...", "")
+	root.AddArg("target", "")
+	assertNoError(t, reg.Freeze())
+	cmd, err := reg.Parse([]string{"a", "b", "c"})
+	assertNoError(t, err)
+	assertEqual(t, "a", cmd.Args["mode"].AsString())
+	assertEqual(t, []string{"b"}, cmd.Args["files"].AsStrings())
+	assertEqual(t, "c", cmd.Args["target"].AsString())
+
+	// impossible layouts
+	invalid := [][]string{
+		{"a...", "b..."},
+		{"a...", "b?"},
+		{"a", "b...", "c", "d?"},
+	}
+	for _, names := range invalid {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		for _, name := range names {
+			arg := root.AddArg(strings.TrimSuffix(name, "?"), "")
+			if strings.HasSuffix(name, "?") {
+				arg.Optional()
+			}
+		}
+		assertError(t, reg.Freeze(), "%v", names)
+	}
+}
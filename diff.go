@@ -0,0 +1,390 @@
+package clapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is a difference between two versions of a command-line interface
+// (see `Diff`).
+type Change struct {
+	// name of the changed command ("" for the root command)
+	Command string
+
+	// name of the changed argument or flag, if any
+	Arg  string
+	Flag string
+
+	// description of the change
+	Message string
+
+	// if true, the change can break command lines that worked with the previous version
+	Breaking bool
+}
+
+func (c Change) String() string {
+	var b strings.Builder
+	if c.Command == "" {
+		b.WriteString("root command")
+	} else {
+		fmt.Fprintf(&b, "command %s", c.Command)
+	}
+	switch {
+	case c.Arg != "":
+		fmt.Fprintf(&b, ": argument %s", c.Arg)
+	case c.Flag != "":
+		fmt.Fprintf(&b, ": flag --%s", c.Flag)
+	}
+	fmt.Fprintf(&b, ": %s", c.Message)
+	if c.Breaking {
+		b.WriteString(" (breaking)")
+	}
+	return b.String()
+}
+
+// Diff compares two versions of a command-line interface, `before` and
+// `after`, and returns their differences, sorted by command. Breaking changes
+// are the ones that can make command lines that worked with `before` fail or
+// change meaning with `after`:
+//
+//   - a removed command, argument or flag
+//   - a changed or removed short name of a flag
+//   - a changed type or kind of an argument or a flag, or a changed separator
+//     of a list flag
+//   - narrowed allowed values
+//   - a new required argument, an argument which is no longer optional or
+//     variadic, strict arguments turned on, or abbreviated flags turned off
+//
+// Other changes, like new commands and flags, widened allowed values and
+// changed defaults and descriptions, are not breaking.
+//
+// The registries are compared by their JSON exports (see `Registry.MarshalJSON`).
+func Diff(before *Registry, after *Registry) ([]Change, error) {
+	oldData, err := before.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	newData, err := after.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return DiffJSON(oldData, newData)
+}
+
+// DiffJSON compares two versions of a command-line interface defined by JSON
+// documents (see `NewRegistryFromJSON` and `Diff`).
+func DiffJSON(before []byte, after []byte) ([]Change, error) {
+	var oldSchema, newSchema schemaRegistry
+	if err := json.Unmarshal(before, &oldSchema); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &newSchema); err != nil {
+		return nil, err
+	}
+
+	oldCommands := schemaCommandsByName(oldSchema)
+	newCommands := schemaCommandsByName(newSchema)
+	names := make([]string, 0, len(oldCommands)+len(newCommands))
+	for name := range oldCommands {
+		names = append(names, name)
+	}
+	for name := range newCommands {
+		if _, ok := oldCommands[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	d := differ{}
+	for _, name := range names {
+		oldCommand, inOld := oldCommands[name]
+		newCommand, inNew := newCommands[name]
+		d.command = name
+		switch {
+		case !inNew:
+			d.add("", "", "removed", true)
+		case !inOld:
+			d.add("", "", "added", false)
+		default:
+			d.diffCommand(oldCommand, newCommand)
+		}
+	}
+	return d.changes, d.err
+}
+
+// schemaCommandsByName returns the commands of a JSON schema by name.
+func schemaCommandsByName(schema schemaRegistry) map[string]schemaCommand {
+	commands := make(map[string]schemaCommand, len(schema.Commands))
+	for _, command := range schema.Commands {
+		commands[command.Name] = command
+	}
+	return commands
+}
+
+// differ collects the changes between two versions of the commands.
+type differ struct {
+	command string
+	changes []Change
+	err     error
+}
+
+// add adds a change of the current command, or of its argument `arg` or flag `flag`.
+func (d *differ) add(arg string, flag string, message string, breaking bool) {
+	d.changes = append(d.changes, Change{Command: d.command, Arg: arg, Flag: flag, Message: message, Breaking: breaking})
+}
+
+// diffCommand compares two versions of a command.
+func (d *differ) diffCommand(before schemaCommand, after schemaCommand) {
+	if before.Description != after.Description {
+		d.add("", "", "description changed", false)
+	}
+	if before.StrictArgs != after.StrictArgs {
+		d.add("", "", fmt.Sprintf("strict arguments changed from %v to %v", before.StrictArgs, after.StrictArgs), after.StrictArgs)
+	}
+	if before.AbbreviateFlags != after.AbbreviateFlags {
+		d.add("", "", fmt.Sprintf("abbreviated flags changed from %v to %v", before.AbbreviateFlags, after.AbbreviateFlags), before.AbbreviateFlags)
+	}
+
+	// arguments are compared by position
+	for i := 0; i < len(before.Args) || i < len(after.Args); i++ {
+		switch {
+		case i >= len(after.Args):
+			d.add(before.Args[i].Name, "", "removed", true)
+		case i >= len(before.Args):
+			arg := after.Args[i]
+			d.add(arg.Name, "", "added", !arg.Optional && !arg.Variadic)
+		default:
+			d.diffArg(before.Args[i], after.Args[i])
+		}
+	}
+
+	oldFlags := make(map[string]schemaFlag, len(before.Flags))
+	for _, flag := range before.Flags {
+		oldFlags[flag.Name] = flag
+	}
+	newFlags := make(map[string]schemaFlag, len(after.Flags))
+	for _, flag := range after.Flags {
+		newFlags[flag.Name] = flag
+	}
+	names := make([]string, 0, len(oldFlags)+len(newFlags))
+	for name := range oldFlags {
+		names = append(names, name)
+	}
+	for name := range newFlags {
+		if _, ok := oldFlags[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldFlag, inOld := oldFlags[name]
+		newFlag, inNew := newFlags[name]
+		switch {
+		case !inNew:
+			d.add("", name, "removed", true)
+		case !inOld:
+			d.add("", name, "added", false)
+		default:
+			d.diffFlag(oldFlag, newFlag)
+		}
+	}
+}
+
+// diffArg compares two versions of the argument at the same position.
+func (d *differ) diffArg(before schemaArg, after schemaArg) {
+	name := after.Name
+	if before.Name != after.Name {
+		d.add(name, "", fmt.Sprintf("renamed from %s", before.Name), false)
+	}
+	if before.Description != after.Description {
+		d.add(name, "", "description changed", false)
+	}
+	if before.Variadic != after.Variadic {
+		d.add(name, "", fmt.Sprintf("variadic changed from %v to %v", before.Variadic, after.Variadic), before.Variadic)
+	}
+	if before.Optional != after.Optional {
+		d.add(name, "", fmt.Sprintf("optional changed from %v to %v", before.Optional, after.Optional), before.Optional)
+	}
+	d.diffValues(name, "", before.Type, after.Type, before.Default, after.Default, before.Allowed, after.Allowed)
+}
+
+// diffFlag compares two versions of a flag.
+func (d *differ) diffFlag(before schemaFlag, after schemaFlag) {
+	name := after.Name
+	if before.Description != after.Description {
+		d.add("", name, "description changed", false)
+	}
+	switch {
+	case before.Short == after.Short:
+	case before.Short == "":
+		d.add("", name, fmt.Sprintf("short name -%s added", after.Short), false)
+	case after.Short == "":
+		d.add("", name, fmt.Sprintf("short name -%s removed", before.Short), true)
+	default:
+		d.add("", name, fmt.Sprintf("short name changed from -%s to -%s", before.Short, after.Short), true)
+	}
+	if before.Kind != after.Kind {
+		d.add("", name, fmt.Sprintf("kind changed from %s to %s", schemaKindName(before.Kind), schemaKindName(after.Kind)), true)
+		return
+	}
+	if before.Separator != after.Separator {
+		d.add("", name, fmt.Sprintf("separator changed from %q to %q", before.Separator, after.Separator), true)
+	}
+
+	// the defaults of map flags are objects of entries
+	oldDefault, newDefault := before.Default, after.Default
+	if before.Kind == "map" {
+		oldDefault, newDefault = nil, nil
+	}
+	if !d.diffValues("", name, before.Type, after.Type, oldDefault, newDefault, before.Allowed, after.Allowed) {
+		return
+	}
+	if before.Kind == "map" && !d.equalEntries(before.Type, before.Default, after.Default) {
+		d.add("", name, fmt.Sprintf("default changed from %s to %s", rawOrNone(before.Default), rawOrNone(after.Default)), false)
+	}
+	if !d.equalValues(before.Type, before.Implicit, after.Implicit) {
+		d.add("", name, fmt.Sprintf("implicit value changed from %s to %s", rawOrNone(before.Implicit), rawOrNone(after.Implicit)), false)
+	}
+}
+
+// diffValues compares the types, defaults and allowed values of two versions
+// of an argument or a flag. It returns `false` if the type changed.
+func (d *differ) diffValues(arg string, flag string, oldType string, newType string, oldDefault json.RawMessage, newDefault json.RawMessage, oldAllowed []json.RawMessage, newAllowed []json.RawMessage) bool {
+	if schemaTypeName(oldType) != schemaTypeName(newType) {
+		d.add(arg, flag, fmt.Sprintf("type changed from %s to %s", schemaTypeName(oldType), schemaTypeName(newType)), true)
+		return false
+	}
+
+	if !d.equalValues(oldType, oldDefault, newDefault) {
+		d.add(arg, flag, fmt.Sprintf("default changed from %s to %s", rawOrNone(oldDefault), rawOrNone(newDefault)), false)
+	}
+
+	switch {
+	case oldAllowed == nil && newAllowed == nil:
+	case newAllowed == nil:
+		d.add(arg, flag, "allowed values removed", false)
+	case oldAllowed == nil:
+		d.add(arg, flag, fmt.Sprintf("allowed values %s added", rawList(newAllowed)), true)
+	default:
+		removed := d.missingValues(oldType, oldAllowed, newAllowed)
+		added := d.missingValues(oldType, newAllowed, oldAllowed)
+		if len(removed) > 0 {
+			d.add(arg, flag, fmt.Sprintf("allowed values %s removed", rawList(removed)), true)
+		}
+		if len(added) > 0 {
+			d.add(arg, flag, fmt.Sprintf("allowed values %s added", rawList(added)), false)
+		}
+	}
+	return true
+}
+
+// missingValues returns the values of `values` which are not in `others`.
+func (d *differ) missingValues(typeName string, values []json.RawMessage, others []json.RawMessage) []json.RawMessage {
+	var missing []json.RawMessage
+	for _, value := range values {
+		found := false
+		for _, other := range others {
+			if d.equalValues(typeName, value, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+// equalValues tells if two JSON values of type `typeName` are equal, like
+// `"1GiB"` and `1073741824` for a `bytesize`.
+func (d *differ) equalValues(typeName string, a json.RawMessage, b json.RawMessage) bool {
+	t, err := schemaType(typeName)
+	if err != nil {
+		d.setErr(err)
+		return true
+	}
+	_a, err := decodeSchemaValue(a, t)
+	if err != nil {
+		d.setErr(err)
+		return true
+	}
+	_b, err := decodeSchemaValue(b, t)
+	if err != nil {
+		d.setErr(err)
+		return true
+	}
+	return equalValues(_a, _b) || reflect.DeepEqual(_a, _b)
+}
+
+// equalEntries tells if two JSON objects of entries of type `typeName` are equal.
+func (d *differ) equalEntries(typeName string, a json.RawMessage, b json.RawMessage) bool {
+	var _a, _b map[string]json.RawMessage
+	if a != nil {
+		if err := json.Unmarshal(a, &_a); err != nil {
+			d.setErr(err)
+			return true
+		}
+	}
+	if b != nil {
+		if err := json.Unmarshal(b, &_b); err != nil {
+			d.setErr(err)
+			return true
+		}
+	}
+
+	if len(_a) != len(_b) {
+		return false
+	}
+	for key, value := range _a {
+		other, ok := _b[key]
+		if !ok || !d.equalValues(typeName, value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// setErr records the first error of the comparison.
+func (d *differ) setErr(err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("command %q: %v", d.command, err)
+	}
+}
+
+// schemaTypeName returns the type name of a JSON schema, `string` if it is empty.
+func schemaTypeName(name string) string {
+	if name == "" {
+		return "string"
+	}
+	return name
+}
+
+// schemaKindName returns the kind of a flag of a JSON schema, `value` if it is empty.
+func schemaKindName(kind string) string {
+	if kind == "" {
+		return "value"
+	}
+	return kind
+}
+
+// rawOrNone formats a JSON value, `none` if it is empty.
+func rawOrNone(raw json.RawMessage) string {
+	if raw == nil {
+		return "none"
+	}
+	return string(raw)
+}
+
+// rawList formats a list of JSON values.
+func rawList(values []json.RawMessage) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = string(value)
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,91 @@
+package clapper
+
+import (
+	"testing"
+)
+
+// test comparing two versions of a command-line interface
+func TestDiff(t *testing.T) {
+	before := `{"commands": [
+		{"name": "", "args": [{"name": "output"}], "flags": [{"name": "force", "short": "f", "type": "bool"}]},
+		{"name": "ghost"},
+		{"name": "info", "args": [
+			{"name": "category", "allowed": ["manager", "student"]},
+			{"name": "subjects", "variadic": true}
+		], "flags": [
+			{"name": "verbose", "short": "v", "type": "bool"},
+			{"name": "version", "short": "V", "default": "1.0.1"},
+			{"name": "cache", "type": "bytesize", "allowed": ["1GiB", "2GiB"]},
+			{"name": "timeout", "type": "duration"},
+			{"name": "hosts", "kind": "list"},
+			{"name": "label", "kind": "map", "default": {"tier": "web"}}
+		]}
+	]}`
+	after := `{"commands": [
+		{"name": "", "description": "Root", "args": [{"name": "output"}, {"name": "input"}], "flags": [{"name": "force", "type": "bool"}]},
+		{"name": "info", "strictArgs": true, "args": [
+			{"name": "category", "allowed": ["student", "manager", "teacher"]},
+			{"name": "subject"}
+		], "flags": [
+			{"name": "verbose", "short": "b", "type": "bool"},
+			{"name": "version", "short": "V", "default": "1.0.2"},
+			{"name": "cache", "type": "bytesize", "allowed": [1073741824]},
+			{"name": "timeout", "type": "int"},
+			{"name": "hosts", "kind": "list", "separator": ";"},
+			{"name": "label", "kind": "map", "default": {"tier": "db"}},
+			{"name": "output", "short": "o"}
+		]},
+		{"name": "serve", "args": [{"name": "port", "type": "uint16"}]}
+	]}`
+
+	changes, err := DiffJSON([]byte(before), []byte(after))
+	assertNoError(t, err)
+
+	expected := []string{
+		"root command: description changed",
+		"root command: argument input: added (breaking)",
+		"root command: flag --force: short name -f removed (breaking)",
+		"command ghost: removed (breaking)",
+		"command info: strict arguments changed from false to true (breaking)",
+		`command info: argument category: allowed values "teacher" added`,
+		"command info: argument subject: renamed from subjects",
+		"command info: argument subject: variadic changed from true to false (breaking)",
+		`command info: flag --cache: allowed values "2GiB" removed (breaking)`,
+		`command info: flag --hosts: separator changed from "" to ";" (breaking)`,
+		`command info: flag --label: default changed from {"tier": "web"} to {"tier": "db"}`,
+		"command info: flag --output: added",
+		"command info: flag --timeout: type changed from duration to int (breaking)",
+		"command info: flag --verbose: short name changed from -v to -b (breaking)",
+		`command info: flag --version: default changed from "1.0.1" to "1.0.2"`,
+		"command serve: added",
+	}
+	actual := make([]string, len(changes))
+	for i, change := range changes {
+		actual[i] = change.String()
+	}
+	assertEqual(t, expected, actual)
+
+	// identical versions have no differences
+	reg, err := NewRegistryFromJSON([]byte(before))
+	assertNoError(t, err)
+	changes, err = Diff(reg, reg)
+	assertNoError(t, err)
+	assertEqual(t, 0, len(changes))
+
+	// non-breaking changes only
+	next, err := NewRegistryFromJSON([]byte(before))
+	assertNoError(t, err)
+	next.Command("").AddArg("extra", "").Optional()
+	flag, _ := next.Command("info").AddFlag("dry-run", "n", false)
+	flag.Description = "Only print"
+	changes, err = Diff(reg, next)
+	assertNoError(t, err)
+	assertEqual(t, 2, len(changes))
+	for _, change := range changes {
+		assertEqual(t, false, change.Breaking, " (%s)", change)
+	}
+
+	// invalid values are errors
+	_, err = DiffJSON([]byte(`{"commands": [{"name": "ghost", "args": [{"name": "x", "type": "int", "default": "x"}]}]}`), []byte(`{"commands": [{"name": "ghost", "args": [{"name": "x", "type": "int"}]}]}`))
+	assertError(t, err)
+}
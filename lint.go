@@ -0,0 +1,184 @@
+package clapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaError is a mistake in the registration of a command, an argument or a
+// flag (see `Registry.Validate`).
+type SchemaError struct {
+	// name of the command ("" for the root command)
+	Command string
+
+	// name of the argument or flag, if any
+	Arg  string
+	Flag string
+
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	switch {
+	case e.Arg != "":
+		return fmt.Sprintf("command %q: argument %s: %s", e.Command, e.Arg, e.Message)
+	case e.Flag != "":
+		return fmt.Sprintf("command %q: flag %s: %s", e.Command, e.Flag, e.Message)
+	}
+	return fmt.Sprintf("command %q: %s", e.Command, e.Message)
+}
+
+// SchemaErrors represents all the mistakes found by `Registry.Validate`.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// record records the error of registering the flag `name`, if any, and returns
+// it. Once the flag is registered, the errors of previous attempts are dropped.
+func (commandConfig *CommandConfig) record(name string, err error) error {
+	name = removeWhitespaces(name)
+	if err != nil {
+		commandConfig.problems = append(commandConfig.problems, SchemaError{Command: commandConfig.Name, Flag: name, Message: err.Error()})
+		return err
+	}
+
+	problems := commandConfig.problems[:0]
+	for _, problem := range commandConfig.problems {
+		if problem.Flag == "" || strings.TrimPrefix(problem.Flag, "no-") != strings.TrimPrefix(name, "no-") {
+			problems = append(problems, problem)
+		}
+	}
+	commandConfig.problems = problems
+	return nil
+}
+
+// record records a mistake in the configuration of the argument or flag, if it
+// is registered with a command. The registry of the command must be locked.
+func (a *Arg) record(message string) {
+	commandConfig := a.command
+	if commandConfig == nil {
+		return
+	}
+
+	problem := SchemaError{Command: commandConfig.Name, Arg: a.Name, Message: message}
+	if flag, ok := commandConfig.Flags[a.Name]; ok && &flag.Arg == a {
+		problem.Arg, problem.Flag = "", a.Name
+	}
+	commandConfig.problems = append(commandConfig.problems, problem)
+}
+
+// Validate checks the registered commands, and returns all the mistakes found
+// as `SchemaErrors`, or `nil`:
+//
+//   - flags which could not be registered, e.g. because their short name is
+//     already used by another flag (`AddFlag` returns these errors too)
+//   - commands and arguments registered, and arguments and flags changed
+//     (e.g. with `Arg.WithRange`), once the registry is frozen (see `Freeze`)
+//   - empty names, and names starting with `-` or containing `=`
+//   - ambiguous positional arguments which could not be registered: a second
+//     variadic argument, or an optional argument following a variadic
+//     argument (see `CommandConfig.AddArg` and `Arg.Optional`)
+//   - default values of unsupported types, like `float32`, a struct, or a
+//     `Value` which is not a pointer
+//   - patterns which don't compile (see `Arg.WithPattern`)
+//   - constraints which don't apply to the type of their argument or flag
+//     (see `Arg.WithRange`, `Arg.WithLength`, `Arg.WithPattern` and `Arg.WithCount`)
+//
+// Validate is safe for concurrent use.
+func (registry *Registry) Validate() error {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return registry.validate()
+}
+
+// validate checks the registered commands (see `Validate`). The registry must be locked.
+func (registry *Registry) validate() error {
+	names := make([]string, 0, len(registry.commands))
+	for name := range registry.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := append(SchemaErrors(nil), registry.problems...)
+	for _, name := range names {
+		errs = append(errs, registry.commands[name].lint()...)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// lint returns the mistakes in the registration of the command.
+func (commandConfig *CommandConfig) lint() []SchemaError {
+	errs := append([]SchemaError(nil), commandConfig.problems...)
+	add := func(arg string, flag string, format string, a ...interface{}) {
+		errs = append(errs, SchemaError{Command: commandConfig.Name, Arg: arg, Flag: flag, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if strings.HasPrefix(commandConfig.Name, "-") || strings.Contains(commandConfig.Name, "=") {
+		add("", "", "illegal command name, must not start with - or contain =")
+	}
+
+	for _, argName := range commandConfig.ArgNames {
+		arg := commandConfig.Args[argName]
+		if message := lintName(argName); message != "" {
+			add(argName, "", "%s", message)
+		}
+		if p := arg.elemType(); p != nil && !supportsType(p) {
+			add(argName, "", "unsupported type %v", p)
+		}
+		if err := arg.checkConstraintTypes(arg.isVariadic); err != nil {
+			add(argName, "", "%v", err)
+		}
+	}
+
+	flagNames := make([]string, 0, len(commandConfig.Flags))
+	for name := range commandConfig.Flags {
+		flagNames = append(flagNames, name)
+	}
+	sort.Strings(flagNames)
+
+	for _, name := range flagNames {
+		flag := commandConfig.Flags[name]
+		if message := lintName(name); message != "" {
+			add("", name, "%s", message)
+		}
+		if flag.ShortName == "-" || flag.ShortName == "=" {
+			add("", name, "illegal short name %s", flag.ShortName)
+		}
+
+		if p := flag.elemType(); p != nil && !supportsType(p) {
+			add("", name, "unsupported type %v", p)
+		}
+		if err := flag.checkConstraintTypes(flag.kind == listFlag); err != nil {
+			add("", name, "%v", err)
+		}
+	}
+
+	return errs
+}
+
+// lintName returns the mistake in the name of an argument or a flag, if any.
+func lintName(name string) string {
+	switch {
+	case name == "":
+		return "name must not be empty"
+	case strings.HasPrefix(name, "-"):
+		return "illegal name, must not start with -"
+	case strings.Contains(name, "="):
+		return "illegal name, must not contain ="
+	case strings.Contains(name, "..."):
+		return "illegal name, must not contain ... except at the end of a variadic argument"
+	}
+	return ""
+}
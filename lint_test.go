@@ -0,0 +1,118 @@
+package clapper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// test validating the registered commands
+func TestRegistryValidate(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("output", "")
+	root.AddFlag("verbose", "v", false)
+	info, _ := reg.Register("info")
+	info.AddArg("category", "").Optional()
+	info.AddArg("subjects...", "")
+	info.AddArg("username", "")
+	assertNoError(t, reg.Validate())
+	assertNoError(t, reg.Freeze())
+
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddArg("files...", "")
+	root.AddArg("=input", "")
+	root.AddArg("dirs...", "")
+	root.AddArg("target", "").Optional()
+	root.AddArg("count", "").WithRange(1, 10)
+	_, err := root.AddFlag("verbose", "v", false)
+	assertNoError(t, err)
+	_, err = root.AddFlag("version", "v", "")
+	assertError(t, err)
+	_, err = root.AddListFlag("force", "f", false, "")
+	assertError(t, err)
+	root.AddFlag("-dir", "-", "")
+	root.AddFlag("a=b", "", "")
+	bad, _ := reg.Register("-x")
+	bad.AddArg("a", "")
+
+	err = reg.Validate()
+	var errs SchemaErrors
+	assertEqual(t, true, errors.As(err, &errs))
+	expected := []string{
+		`command "": argument dirs: variadic arguments files and dirs are ambiguous`,
+		`command "": argument target: optional argument must not follow variadic argument files`,
+		`command "": flag version: short name v is already used by flag verbose`,
+		`command "": flag force: boolean flags can not be lists`,
+		`command "": argument =input: illegal name, must not contain =`,
+		`command "": argument count: range of a non-numeric argument of type string`,
+		`command "": flag -dir: illegal name, must not start with -`,
+		`command "": flag -dir: illegal short name -`,
+		`command "": flag a=b: illegal name, must not contain =`,
+		`command "-x": illegal command name, must not start with - or contain =`,
+	}
+	actual := make([]string, len(errs))
+	for i, err := range errs {
+		actual[i] = err.Error()
+	}
+	assertEqual(t, expected, actual)
+
+	// the first flag keeps its short name, and the ambiguous arguments are not registered
+	cmd, err := reg.Parse([]string{"-v"})
+	assertNoError(t, err)
+	assertEqual(t, true, cmd.Flags["verbose"].AsBool())
+	assertEqual(t, []string{"files", "=input", "target", "count"}, root.ArgNames)
+	assertEqual(t, false, root.Args["target"].optional)
+
+	// an invalid registry can't be frozen
+	assertEqual(t, reg.Validate(), reg.Freeze())
+	_, err = root.AddFlag("force", "f", false)
+	assertNoError(t, err)
+
+	// the errors of flags registered at last are dropped
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	_, err = root.AddListFlag("force", "f", false, "")
+	assertError(t, err)
+	_, err = root.AddFlag("no-dry-run", "", "")
+	assertError(t, err)
+	assertError(t, reg.Validate())
+	_, err = root.AddFlag("force", "f", false)
+	assertNoError(t, err)
+	_, err = root.AddFlag("no-dry-run", "", true)
+	assertNoError(t, err)
+	assertNoError(t, reg.Validate())
+	assertNoError(t, reg.Freeze())
+
+	// default values of unsupported types
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddArg("ratios...", []float32{})
+	root.AddFlag("ratio", "", float32(0))
+	root.AddFlag("pt", "", struct{ X, Y int }{})
+	root.AddFlag("level", "", logLevel{})
+	root.AddFlag("weights", "", map[string]float32{})
+	root.AddFlag("log-level", "", &logLevel{})
+	root.AddFlag("delay", "", []float64{})
+
+	err = reg.Validate()
+	assertEqual(t, true, errors.As(err, &errs))
+	expected = []string{
+		`command "": argument ratios: unsupported type float32`,
+		`command "": flag level: unsupported type clapper.logLevel`,
+		`command "": flag pt: unsupported type struct { X int; Y int }`,
+		`command "": flag ratio: unsupported type float32`,
+		`command "": flag weights: unsupported type float32`,
+	}
+	actual = make([]string, len(errs))
+	for i, err := range errs {
+		actual[i] = err.Error()
+	}
+	assertEqual(t, expected, actual)
+
+	// values of unsupported types are not converted
+	_, err = reg.Parse([]string{"--ratio", "0.5"})
+	assertError(t, err)
+	assertEqual(t, true, strings.Contains(err.Error(), "unsupported type float32"), " (%v)", err)
+}
@@ -0,0 +1,231 @@
+package clapper
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// HostPort is a network address made of a host and a port, like
+// `example.com:443`, `10.0.0.1:80` or `[::1]:8080`.
+//
+// If the default value of an argument or a flag is a `HostPort` with a port,
+// command-line argument values without a port (like `example.com`) take it.
+type HostPort struct {
+	Host string
+	Port uint16
+}
+
+// String formats the address, like `example.com:443` or `[::1]:8080`.
+func (h HostPort) String() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(int(h.Port)))
+}
+
+// reflect types of the network types
+var (
+	addrType         = reflect.TypeOf(netip.Addr{})
+	prefixType       = reflect.TypeOf(netip.Prefix{})
+	hostPortType     = reflect.TypeOf(HostPort{})
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr{})
+	urlType          = reflect.TypeOf(&url.URL{})
+)
+
+// WithSchemes restricts the schemes of the URL values of the argument to
+// `schemes` (e.g. `http` and `https`), compared without case.
+func (a *Arg) WithSchemes(schemes ...string) *Arg {
+	return a.update(func() { a.schemes = schemes })
+}
+
+// parseAddr parses an IP address, like `10.0.0.1` or `fe80::1%eth0`.
+func parseAddr(s string) (netip.Addr, error) {
+	return netip.ParseAddr(s)
+}
+
+// parsePrefix parses an IP network in CIDR notation, like `10.0.0.0/8`.
+func parsePrefix(s string) (netip.Prefix, error) {
+	return netip.ParsePrefix(s)
+}
+
+// parseHostPort parses a `host:port` address. Without a port, the address
+// takes the port of the default value of the argument, if any.
+func parseHostPort(s string, defaults interface{}) (HostPort, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// a host without a port takes the default port
+		defaultPort, _ := defaults.(HostPort)
+		host = s
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		if defaultPort.Port == 0 || host == "" || strings.ContainsAny(host, "[]") {
+			return HostPort{}, err
+		}
+		if _, err := netip.ParseAddr(host); strings.Contains(host, ":") && err != nil {
+			return HostPort{}, fmt.Errorf("address %s: invalid host", s)
+		}
+		return HostPort{host, defaultPort.Port}, nil
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return HostPort{}, fmt.Errorf("address %s: invalid port", s)
+	}
+	return HostPort{host, uint16(p)}, nil
+}
+
+// parseURL parses an absolute URL, with one of the schemes of the argument
+// (if restricted with `Arg.WithSchemes`).
+func parseURL(s string, a *Arg) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, BadArgument{Arg: a, Message: fmt.Sprintf("illegal URL %s, must be an absolute URL", s)}
+	}
+
+	if len(a.schemes) == 0 {
+		return u, nil
+	}
+	for _, scheme := range a.schemes {
+		if strings.EqualFold(scheme, u.Scheme) {
+			return u, nil
+		}
+	}
+	return nil, BadArgument{Arg: a, Message: fmt.Sprintf("illegal URL %s, scheme must be %s", s, strings.Join(a.schemes, ", "))}
+}
+
+// equalNetValues compares two network values of the same type semantically:
+// IPv4-mapped IPv6 addresses equal their IPv4 addresses, networks are compared
+// by their masked addresses, hosts and URL schemes and hosts are compared
+// without case. The second return value is `false` if the values are not
+// network values.
+func equalNetValues(a interface{}, b interface{}) (bool, bool) {
+	switch _a := a.(type) {
+	case netip.Addr:
+		return _a.Unmap() == b.(netip.Addr).Unmap(), true
+	case netip.Prefix:
+		_b := b.(netip.Prefix)
+		return _a.Bits() == _b.Bits() && _a.Masked().Addr().Unmap() == _b.Masked().Addr().Unmap(), true
+	case HostPort:
+		_b := b.(HostPort)
+		return _a.Port == _b.Port && equalHosts(_a.Host, _b.Host), true
+	case net.HardwareAddr:
+		return bytes.Equal(_a, b.(net.HardwareAddr)), true
+	case *url.URL:
+		_b := b.(*url.URL)
+		if _a == nil || _b == nil {
+			return _a == _b, true
+		}
+		_aCopy, _bCopy := *_a, *_b
+		_aCopy.Scheme, _bCopy.Scheme = strings.ToLower(_a.Scheme), strings.ToLower(_b.Scheme)
+		_aCopy.Host, _bCopy.Host = strings.ToLower(_a.Host), strings.ToLower(_b.Host)
+		return _aCopy.String() == _bCopy.String(), true
+	}
+	return false, false
+}
+
+// equalHosts compares two hosts, as IP addresses if they are
+func equalHosts(a string, b string) bool {
+	_a, errA := netip.ParseAddr(a)
+	_b, errB := netip.ParseAddr(b)
+	if errA == nil && errB == nil {
+		return _a.Unmap() == _b.Unmap()
+	}
+	return strings.EqualFold(a, b)
+}
+
+func (a Arg) AsAddr() netip.Addr {
+	if v, ok := a.value.(netip.Addr); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(netip.Addr)
+		return v
+	}
+}
+
+func (a Arg) AsPrefix() netip.Prefix {
+	if v, ok := a.value.(netip.Prefix); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(netip.Prefix)
+		return v
+	}
+}
+
+func (a Arg) AsHostPort() HostPort {
+	if v, ok := a.value.(HostPort); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(HostPort)
+		return v
+	}
+}
+
+func (a Arg) AsHardwareAddr() net.HardwareAddr {
+	if v, ok := a.value.(net.HardwareAddr); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(net.HardwareAddr)
+		return v
+	}
+}
+
+func (a Arg) AsURL() *url.URL {
+	if v, ok := a.value.(*url.URL); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(*url.URL)
+		return v
+	}
+}
+
+func (a Arg) AsAddrs() []netip.Addr {
+	if v, ok := a.value.([]netip.Addr); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]netip.Addr)
+		return v
+	}
+}
+
+func (a Arg) AsPrefixes() []netip.Prefix {
+	if v, ok := a.value.([]netip.Prefix); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]netip.Prefix)
+		return v
+	}
+}
+
+func (a Arg) AsHostPorts() []HostPort {
+	if v, ok := a.value.([]HostPort); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]HostPort)
+		return v
+	}
+}
+
+func (a Arg) AsHardwareAddrs() []net.HardwareAddr {
+	if v, ok := a.value.([]net.HardwareAddr); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]net.HardwareAddr)
+		return v
+	}
+}
+
+func (a Arg) AsURLs() []*url.URL {
+	if v, ok := a.value.([]*url.URL); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]*url.URL)
+		return v
+	}
+}
@@ -0,0 +1,202 @@
+package clapper
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// test IP addresses and networks
+func TestAddrs(t *testing.T) {
+	tests := []struct {
+		addr   string
+		prefix string
+		err    bool
+	}{
+		{"10.0.0.1", "10.0.0.0/8", false},
+		{"::1", "fe80::/10", false},
+		{"fe80::1%eth0", "2001:db8::/32", false},
+		{"10.0.0.256", "10.0.0.0/8", true},
+		{"10.0.0.1", "10.0.0.0/33", true},
+		{"10.0.0.1", "10.0.0.0", true},
+		{"example.com", "10.0.0.0/8", true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("bind", "", netip.Addr{})
+		root.AddFlag("allow", "", netip.Prefix{})
+
+		cmd, err := reg.Parse([]string{"--bind", test.addr, "--allow", test.prefix})
+		if test.err {
+			assertError(t, err, "%s %s", test.addr, test.prefix)
+			continue
+		}
+		assertNoError(t, err, "%s %s", test.addr, test.prefix)
+		assertEqual(t, netip.MustParseAddr(test.addr), cmd.Flags["bind"].AsAddr(), " (%s)", test.addr)
+		assertEqual(t, netip.MustParsePrefix(test.prefix), cmd.Flags["allow"].AsPrefix(), " (%s)", test.prefix)
+	}
+
+	// lists
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddListFlag("dns", "", netip.Addr{}, ",")
+	root.AddArg("networks...", netip.Prefix{})
+	cmd, err := reg.Parse([]string{"--dns", "1.1.1.1,::1", "10.0.0.0/8", "192.168.0.0/16"})
+	assertNoError(t, err)
+	assertEqual(t, []netip.Addr{netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("::1")}, cmd.Flags["dns"].AsAddrs())
+	assertEqual(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.0.0/16")}, cmd.Args["networks"].AsPrefixes())
+
+	// allowed values are compared semantically
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddFlag("bind", "", []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")})
+	root.AddFlag("allow", "", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	_, err = reg.Parse([]string{"--bind", "::ffff:10.0.0.1", "--allow", "10.1.2.3/8"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--bind", "0:0:0:0:0:0:0:1"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--bind", "10.0.0.2"})
+	assertError(t, err)
+	_, err = reg.Parse([]string{"--allow", "10.0.0.0/16"})
+	assertError(t, err)
+}
+
+// test host:port addresses
+func TestHostPorts(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected HostPort
+		err      bool
+	}{
+		{"example.com:443", HostPort{"example.com", 443}, false},
+		{"10.0.0.1:80", HostPort{"10.0.0.1", 80}, false},
+		{"[::1]:8080", HostPort{"::1", 8080}, false},
+		{"example.com", HostPort{"example.com", 5432}, false},
+		{"[::1]", HostPort{"::1", 5432}, false},
+		{"::1", HostPort{"::1", 5432}, false},
+		{"example.com:http", HostPort{}, true},
+		{"example.com:65536", HostPort{}, true},
+		{"a:b:c", HostPort{}, true},
+		{"[example.com", HostPort{}, true},
+		{"", HostPort{}, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("db", "", HostPort{"localhost", 5432})
+
+		cmd, err := reg.Parse([]string{"--db=" + test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, test.expected, cmd.Flags["db"].AsHostPort(), " (%s)", test.value)
+	}
+
+	// without a default port, the port is required
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("servers...", HostPort{})
+	cmd, err := reg.Parse([]string{"a:1", "[::1]:2"})
+	assertNoError(t, err)
+	assertEqual(t, []HostPort{{"a", 1}, {"::1", 2}}, cmd.Args["servers"].AsHostPorts())
+	_, err = reg.Parse([]string{"a"})
+	assertError(t, err)
+
+	// formatting and allowed values
+	assertEqual(t, "[::1]:8080", HostPort{"::1", 8080}.String())
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	upstream, _ := root.AddFlag("upstream", "", []HostPort{{"Example.com", 443}, {"10.0.0.1", 80}})
+	_, err = reg.Parse([]string{"--upstream", "example.COM:443"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--upstream", "[::ffff:10.0.0.1]:80"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--upstream", "example.com:80"})
+	assertError(t, err)
+	assertEqual(t, "Example.com:443|10.0.0.1:80", upstream.DefaultString())
+}
+
+// test hardware addresses
+func TestHardwareAddrs(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddFlag("mac", "", net.HardwareAddr{})
+	root.AddArg("macs...", net.HardwareAddr{})
+
+	cmd, err := reg.Parse([]string{"--mac", "00:00:5e:00:53:01", "00-00-5E-00-53-02", "0000.5e00.5303"})
+	assertNoError(t, err)
+	assertEqual(t, net.HardwareAddr{0, 0, 0x5e, 0, 0x53, 1}, cmd.Flags["mac"].AsHardwareAddr())
+	assertEqual(t, []net.HardwareAddr{{0, 0, 0x5e, 0, 0x53, 2}, {0, 0, 0x5e, 0, 0x53, 3}}, cmd.Args["macs"].AsHardwareAddrs())
+	assertEqual(t, "net.HardwareAddr", cmd.Flags["mac"].TypeName())
+
+	_, err = reg.Parse([]string{"--mac", "00:00:5e:00:53"})
+	assertError(t, err)
+
+	// allowed values
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	root.AddFlag("mac", "", []net.HardwareAddr{{0, 0, 0x5e, 0, 0x53, 1}})
+	_, err = reg.Parse([]string{"--mac", "00:00:5E:00:53:01"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--mac", "00:00:5e:00:53:02"})
+	assertError(t, err)
+}
+
+// test URLs
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		value string
+		err   bool
+	}{
+		{"https://example.com/path?q=1", false},
+		{"http://example.com", false},
+		{"HTTPS://example.com", false},
+		{"ftp://example.com", true},
+		{"example.com/path", true},
+		{"/path", true},
+		{"https://example.com/%zz", true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		endpoint, _ := root.AddFlag("endpoint", "", (*url.URL)(nil))
+		endpoint.WithSchemes("http", "https")
+
+		cmd, err := reg.Parse([]string{"--endpoint", test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, strings.ToLower(test.value), strings.ToLower(cmd.Flags["endpoint"].AsURL().String()), " (%s)", test.value)
+	}
+
+	// without a scheme allow-list, any absolute URL is accepted
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("mirrors...", (*url.URL)(nil))
+	cmd, err := reg.Parse([]string{"ftp://example.com", "s3://bucket/key"})
+	assertNoError(t, err)
+	mirrors := cmd.Args["mirrors"].AsURLs()
+	assertEqual(t, 2, len(mirrors))
+	assertEqual(t, "s3", mirrors[1].Scheme)
+	assertEqual(t, "bucket", mirrors[1].Host)
+
+	// allowed values
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	home, _ := url.Parse("https://example.com/home")
+	root.AddFlag("home", "", []*url.URL{home})
+	_, err = reg.Parse([]string{"--home", "HTTPS://EXAMPLE.com/home"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--home", "https://example.com/HOME"})
+	assertError(t, err)
+}
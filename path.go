@@ -0,0 +1,165 @@
+package clapper
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Path is a filesystem path. Path values of arguments are checked against the
+// `PathCheck`s of the argument (see `Arg.WithPathChecks`).
+type Path string
+
+// PathCheck is a set of checks and transformations of the `Path` values of an
+// argument, combined with `|`.
+type PathCheck uint
+
+const (
+	// PathExists requires the path to exist.
+	PathExists PathCheck = 1 << iota
+	// PathNotExists requires the path not to exist.
+	PathNotExists
+	// PathIsFile requires the path to be a regular file, if it exists.
+	PathIsFile
+	// PathIsDir requires the path to be a directory, if it exists.
+	PathIsDir
+	// PathReadable requires the path to be readable.
+	PathReadable
+	// PathWritable requires the path to be writable, or, if it doesn't exist,
+	// its parent directory to be writable.
+	PathWritable
+	// PathParentExists requires the parent directory of the path to exist.
+	PathParentExists
+	// PathExpand expands a leading `~` to the home directory of the user, and
+	// `$VAR` and `${VAR}` to the values of the environment variables.
+	PathExpand
+	// PathAbsolute resolves the path to an absolute path.
+	PathAbsolute
+)
+
+// reflect type of the `Path` type
+var pathType = reflect.TypeOf(Path(""))
+
+// WithPathChecks sets the checks and transformations of the `Path` values of
+// the argument, e.g. `PathExpand | PathIsFile | PathReadable`.
+func (a *Arg) WithPathChecks(checks PathCheck) *Arg {
+	return a.update(func() { a.pathChecks = checks })
+}
+
+// parsePath transforms and checks the path `s` with the path checks of the argument.
+func parsePath(s string, a *Arg) (Path, error) {
+	if s == "" {
+		return "", BadArgument{Arg: a, Message: "illegal path, must not be empty"}
+	}
+
+	checks := a.pathChecks
+	path := s
+	if checks&PathExpand != 0 {
+		var err error
+		if path, err = expandPath(path); err != nil {
+			return "", BadArgument{Arg: a, Message: fmt.Sprintf("illegal path %s, %v", s, err)}
+		}
+	}
+	if checks&PathAbsolute != 0 {
+		var err error
+		if path, err = filepath.Abs(path); err != nil {
+			return "", BadArgument{Arg: a, Message: fmt.Sprintf("illegal path %s, %v", s, err)}
+		}
+	}
+
+	info, err := os.Stat(path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("illegal path %s, %v", s, err)}
+	}
+
+	switch {
+	case checks&PathExists != 0 && !exists:
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s does not exist", path)}
+	case checks&PathNotExists != 0 && exists:
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s already exists", path)}
+	case checks&PathIsFile != 0 && exists && !info.Mode().IsRegular():
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s is not a file", path)}
+	case checks&PathIsDir != 0 && exists && !info.IsDir():
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s is not a directory", path)}
+	}
+
+	if checks&PathParentExists != 0 {
+		if parent, err := os.Stat(filepath.Dir(path)); err != nil || !parent.IsDir() {
+			return "", BadArgument{Arg: a, Message: fmt.Sprintf("parent directory of path %s does not exist", path)}
+		}
+	}
+	if checks&PathReadable != 0 && !(exists && readable(path)) {
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s is not readable", path)}
+	}
+	if checks&PathWritable != 0 && !writable(path, info) {
+		return "", BadArgument{Arg: a, Message: fmt.Sprintf("path %s is not writable", path)}
+	}
+
+	return Path(path), nil
+}
+
+// expandPath expands a leading `~` and the environment variables in `path`.
+// Undefined environment variables are errors.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = home + path[1:]
+	}
+
+	var undefined []string
+	path = os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if len(undefined) > 0 {
+		return "", fmt.Errorf("undefined environment variable %s", strings.Join(undefined, ", "))
+	}
+	return path, nil
+}
+
+// readable tells if the file or directory at `path` can be read.
+func readable(path string) bool {
+	return access(path, accessRead)
+}
+
+// writable tells if the file at `path` can be written, or if a file can be
+// created in the directory at `path` (or in the parent directory of `path`, if
+// it doesn't exist). `info` is the `fs.FileInfo` of `path`, or `nil`.
+func writable(path string, info fs.FileInfo) bool {
+	switch {
+	case info == nil:
+		return access(filepath.Dir(path), accessWrite|accessSearch)
+	case info.IsDir():
+		return access(path, accessWrite|accessSearch)
+	}
+	return access(path, accessWrite)
+}
+
+func (a Arg) AsPath() Path {
+	if v, ok := a.value.(Path); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(Path)
+		return v
+	}
+}
+
+func (a Arg) AsPaths() []Path {
+	if v, ok := a.value.([]Path); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]Path)
+		return v
+	}
+}
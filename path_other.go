@@ -0,0 +1,30 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package clapper
+
+import "os"
+
+// access modes of `access`, as in access(2)
+const (
+	accessRead   = 0x4
+	accessWrite  = 0x2
+	accessSearch = 0x1
+)
+
+// access tells if the file at `path` can be accessed with all the access
+// modes of `mode`, without opening it. Without access(2), a mode is allowed if
+// it is allowed to any user by the permission bits of the file.
+func access(path string, mode uint32) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	perm := uint32(info.Mode().Perm())
+	for _, m := range []uint32{accessRead, accessWrite, accessSearch} {
+		if mode&m != 0 && perm&(m*0o111) == 0 {
+			return false
+		}
+	}
+	return true
+}
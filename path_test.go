@@ -0,0 +1,113 @@
+package clapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// test path checks
+func TestPaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	assertNoError(t, os.WriteFile(file, []byte("a: b"), 0o644))
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		value  string
+		checks PathCheck
+		err    bool
+	}{
+		{file, PathExists, false},
+		{missing, PathExists, true},
+		{missing, PathNotExists, false},
+		{file, PathNotExists, true},
+		{file, PathIsFile, false},
+		{dir, PathIsFile, true},
+		{missing, PathIsFile, false},
+		{dir, PathIsDir, false},
+		{file, PathIsDir | PathExists, true},
+		{file, PathReadable, false},
+		{dir, PathReadable, false},
+		{missing, PathReadable, true},
+		{file, PathWritable, false},
+		{dir, PathWritable, false},
+		{missing, PathWritable, false},
+		{filepath.Join(missing, "file"), PathWritable, true},
+		{missing, PathParentExists, false},
+		{filepath.Join(missing, "file"), PathParentExists, true},
+		{filepath.Join(file, "file"), PathParentExists, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		config, _ := root.AddFlag("config", "c", Path(""))
+		config.WithPathChecks(test.checks)
+
+		cmd, err := reg.Parse([]string{"--config=" + test.value})
+		if test.err {
+			assertError(t, err, "%s %b", test.value, test.checks)
+			if _, ok := err.(BadArgument); !ok {
+				t.Errorf("expected a BadArgument error, got %#v (%s %b)", err, test.value, test.checks)
+			}
+			continue
+		}
+		assertNoError(t, err, "%s %b", test.value, test.checks)
+		assertEqual(t, Path(test.value), cmd.Flags["config"].AsPath(), " (%s %b)", test.value, test.checks)
+	}
+
+	// no file is left behind by the writable check
+	entries, err := os.ReadDir(dir)
+	assertNoError(t, err)
+	assertEqual(t, 1, len(entries))
+}
+
+// test path expansion and resolution
+func TestPathExpansion(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("CLAPPER_DIR", "config")
+
+	tests := []struct {
+		value    string
+		checks   PathCheck
+		expected string
+		err      bool
+	}{
+		{"~", PathExpand, dir, false},
+		{"~/.config", PathExpand, filepath.Join(dir, ".config"), false},
+		{"~other/.config", PathExpand, "~other/.config", false},
+		{"$HOME/$CLAPPER_DIR", PathExpand, filepath.Join(dir, "config"), false},
+		{"${HOME}/${CLAPPER_DIR}.d", PathExpand, filepath.Join(dir, "config.d"), false},
+		{"$HOME/$CLAPPER_UNDEFINED", PathExpand, "", true},
+		{"~/$CLAPPER_DIR", 0, "~/$CLAPPER_DIR", false},
+		{"~", PathExpand | PathIsDir | PathExists, dir, false},
+		{"~/missing", PathExpand | PathExists, "", true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddArg("path", Path("")).WithPathChecks(test.checks)
+
+		cmd, err := reg.Parse([]string{test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, Path(test.expected), cmd.Args["path"].AsPath(), " (%s)", test.value)
+	}
+
+	// absolute paths
+	wd, err := os.Getwd()
+	assertNoError(t, err)
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("files...", Path("")).WithPathChecks(PathAbsolute | PathExists)
+	cmd, err := reg.Parse([]string{"path.go", "./net.go"})
+	assertNoError(t, err)
+	assertEqual(t, []Path{Path(filepath.Join(wd, "path.go")), Path(filepath.Join(wd, "net.go"))}, cmd.Args["files"].AsPaths())
+}
@@ -0,0 +1,18 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package clapper
+
+import "syscall"
+
+// access modes of `access`, as in access(2)
+const (
+	accessRead   = 0x4
+	accessWrite  = 0x2
+	accessSearch = 0x1
+)
+
+// access tells if the file at `path` can be accessed with all the access
+// modes of `mode` by the real user, without opening it (see access(2)).
+func access(path string, mode uint32) bool {
+	return syscall.Access(path, mode) == nil
+}
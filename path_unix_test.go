@@ -0,0 +1,34 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package clapper
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// test that permission checks don't open a FIFO, which would block
+func TestPathFIFO(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	assertNoError(t, syscall.Mkfifo(fifo, 0o600))
+
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	config, _ := root.AddFlag("config", "c", Path(""))
+	config.WithPathChecks(PathReadable | PathWritable)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := reg.Parse([]string{"--config", fifo})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assertNoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the permission checks of a FIFO are blocked")
+	}
+}
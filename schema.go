@@ -0,0 +1,447 @@
+package clapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// schemaTypes maps the type names of a JSON schema to the supported types.
+var schemaTypes = map[string]reflect.Type{
+	"string":   reflect.TypeOf(""),
+	"bool":     reflect.TypeOf(false),
+	"int":      reflect.TypeOf(int(0)),
+	"int8":     reflect.TypeOf(int8(0)),
+	"int16":    reflect.TypeOf(int16(0)),
+	"int32":    reflect.TypeOf(int32(0)),
+	"int64":    reflect.TypeOf(int64(0)),
+	"uint":     reflect.TypeOf(uint(0)),
+	"uint8":    reflect.TypeOf(uint8(0)),
+	"uint16":   reflect.TypeOf(uint16(0)),
+	"uint32":   reflect.TypeOf(uint32(0)),
+	"uint64":   reflect.TypeOf(uint64(0)),
+	"float64":  reflect.TypeOf(float64(0)),
+	"time":     timeType,
+	"duration": durationType,
+	"bytesize": byteSizeType,
+	"percent":  percentType,
+	"addr":     addrType,
+	"prefix":   prefixType,
+	"hostport": hostPortType,
+	"mac":      hardwareAddrType,
+	"url":      urlType,
+	"path":     pathType,
+}
+
+// schemaRegistry is the JSON schema of a registry.
+type schemaRegistry struct {
+	Commands []schemaCommand `json:"commands"`
+}
+
+// schemaCommand is the JSON schema of a command.
+type schemaCommand struct {
+	Name            string       `json:"name"`
+	Description     string       `json:"description,omitempty"`
+	AbbreviateFlags bool         `json:"abbreviateFlags,omitempty"`
+	StrictArgs      bool         `json:"strictArgs,omitempty"`
+	Args            []schemaArg  `json:"args,omitempty"`
+	Flags           []schemaFlag `json:"flags,omitempty"`
+}
+
+// schemaArg is the JSON schema of an argument.
+type schemaArg struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Default     json.RawMessage   `json:"default,omitempty"`
+	Allowed     []json.RawMessage `json:"allowed,omitempty"`
+	Variadic    bool              `json:"variadic,omitempty"`
+	Optional    bool              `json:"optional,omitempty"`
+}
+
+// schemaFlag is the JSON schema of a flag.
+type schemaFlag struct {
+	Name        string            `json:"name"`
+	Short       string            `json:"short,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Kind        string            `json:"kind,omitempty"`
+	Default     json.RawMessage   `json:"default,omitempty"`
+	Allowed     []json.RawMessage `json:"allowed,omitempty"`
+	Implicit    json.RawMessage   `json:"implicit,omitempty"`
+	Separator   string            `json:"separator,omitempty"`
+}
+
+// kinds of flags in a JSON schema
+var schemaKinds = map[flagKind]string{
+	valueFlag:         "",
+	optionalValueFlag: "optional",
+	listFlag:          "list",
+	mapFlag:           "map",
+}
+
+// NewRegistryFromJSON returns a new registry with the commands, arguments and
+// flags of a JSON document, like:
+//
+//	{
+//	  "commands": [
+//	    {
+//	      "name": "info",
+//	      "description": "Show information",
+//	      "args": [
+//	        {"name": "category", "allowed": ["manager", "student"]},
+//	        {"name": "subjects", "variadic": true}
+//	      ],
+//	      "flags": [
+//	        {"name": "verbose", "short": "v", "type": "bool"},
+//	        {"name": "timeout", "type": "duration", "default": "30s"},
+//	        {"name": "label", "type": "string", "kind": "map"}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// A command has a `name` ("" for the root command), and optionally a
+// `description`, `abbreviateFlags` and `strictArgs` (see `CommandConfig`).
+//
+// Arguments and flags have a `name`, and optionally:
+//
+//   - a `description`
+//   - a `type` (`string` by default): one of `string`, `bool`, `int`, `int8`,
+//     `int16`, `int32`, `int64`, `uint`, `uint8`, `uint16`, `uint32`,
+//     `uint64`, `float64`, `time`, `duration`, `bytesize`, `percent`, `addr`,
+//     `prefix`, `hostport`, `mac`, `url` or `path`
+//   - a `default` value, or the `allowed` values (see `CommandConfig.AddArg`);
+//     values are JSON strings in the command-line format (like `"30s"` or
+//     `"512MiB"`), or JSON numbers and booleans for numbers and booleans
+//
+// Arguments can be `variadic` and `optional`. Flags can have a `short` name and
+// a `kind`: `optional` for a flag with an `implicit` value (see
+// `CommandConfig.AddOptionalFlag`), `list` for a list flag with a `separator`
+// (see `CommandConfig.AddListFlag`), or `map` for a map flag with values of the
+// type (see `CommandConfig.AddFlag`).
+func NewRegistryFromJSON(data []byte) (*Registry, error) {
+	var schema schemaRegistry
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, err
+	}
+
+	registry := NewRegistry()
+	for _, command := range schema.Commands {
+		if registry.Command(command.Name) != nil {
+			return nil, fmt.Errorf("command %q is defined twice", command.Name)
+		}
+		if err := command.register(registry); err != nil {
+			return nil, fmt.Errorf("command %q: %v", command.Name, err)
+		}
+	}
+
+	if err := registry.validate(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// register registers the command, its arguments and its flags.
+func (command schemaCommand) register(registry *Registry) error {
+	commandConfig, _ := registry.Register(command.Name)
+	commandConfig.Description = command.Description
+	commandConfig.AbbreviateFlags = command.AbbreviateFlags
+	commandConfig.StrictArgs = command.StrictArgs
+
+	for _, arg := range command.Args {
+		if _, ok := commandConfig.Args[arg.Name]; ok {
+			return fmt.Errorf("argument %s is defined twice", arg.Name)
+		}
+		defaultValue, err := decodeSchemaDefault(arg.Type, arg.Default, arg.Allowed)
+		if err != nil {
+			return fmt.Errorf("argument %s: %v", arg.Name, err)
+		}
+
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		registered := commandConfig.AddArg(name, defaultValue)
+		registered.Description = arg.Description
+		if arg.Optional {
+			registered.Optional()
+		}
+	}
+
+	for _, flag := range command.Flags {
+		if _, ok := commandConfig.Flags[flag.Name]; ok {
+			return fmt.Errorf("flag %s is defined twice", flag.Name)
+		}
+		registered, err := flag.register(commandConfig)
+		if err != nil {
+			return fmt.Errorf("flag %s: %v", flag.Name, err)
+		}
+		registered.Description = flag.Description
+	}
+	return nil
+}
+
+// register registers the flag with the command.
+func (flag schemaFlag) register(commandConfig *CommandConfig) (*Flag, error) {
+	if flag.Kind == "map" {
+		if flag.Allowed != nil {
+			return nil, fmt.Errorf("map flags can not have allowed values")
+		}
+		t, err := schemaType(flag.Type)
+		if err != nil {
+			return nil, err
+		}
+		entries := make(map[string]json.RawMessage)
+		if flag.Default != nil {
+			if err := json.Unmarshal(flag.Default, &entries); err != nil {
+				return nil, fmt.Errorf("illegal default %s, must be an object", flag.Default)
+			}
+		}
+		defaultValue := reflect.MakeMap(reflect.MapOf(reflect.TypeOf(""), t))
+		for key, raw := range entries {
+			value, err := decodeSchemaValue(raw, t)
+			if err != nil {
+				return nil, err
+			}
+			defaultValue.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		}
+		return commandConfig.AddFlag(flag.Name, flag.Short, defaultValue.Interface())
+	}
+
+	defaultValue, err := decodeSchemaDefault(flag.Type, flag.Default, flag.Allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	switch flag.Kind {
+	case "":
+		return commandConfig.AddFlag(flag.Name, flag.Short, defaultValue)
+	case "optional":
+		t := reflect.TypeOf(defaultValue)
+		if isList(t) {
+			t = t.Elem()
+		}
+		implicitValue, err := decodeSchemaValue(flag.Implicit, t)
+		if err != nil {
+			return nil, fmt.Errorf("implicit value: %v", err)
+		}
+		return commandConfig.AddOptionalFlag(flag.Name, flag.Short, defaultValue, implicitValue)
+	case "list":
+		return commandConfig.AddListFlag(flag.Name, flag.Short, defaultValue, flag.Separator)
+	}
+	return nil, fmt.Errorf("unknown kind %s", flag.Kind)
+}
+
+// schemaType returns the type of the type name `name` of a JSON schema.
+func schemaType(name string) (reflect.Type, error) {
+	if name == "" {
+		name = "string"
+	}
+	t, ok := schemaTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	return t, nil
+}
+
+// decodeSchemaDefault returns the default value of an argument or a flag of
+// the type `typeName`: the `allowed` values, the `raw` default value, or the
+// zero value of the type.
+func decodeSchemaDefault(typeName string, raw json.RawMessage, allowed []json.RawMessage) (interface{}, error) {
+	t, err := schemaType(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case allowed != nil && raw != nil:
+		return nil, fmt.Errorf("a default value and allowed values are exclusive")
+	case allowed != nil:
+		values := reflect.MakeSlice(reflect.SliceOf(t), 0, len(allowed))
+		for _, raw := range allowed {
+			value, err := decodeSchemaValue(raw, t)
+			if err != nil {
+				return nil, err
+			}
+			values = reflect.Append(values, reflect.ValueOf(value))
+		}
+		return values.Interface(), nil
+	case raw != nil:
+		return decodeSchemaValue(raw, t)
+	}
+	return reflect.Zero(t).Interface(), nil
+}
+
+// decodeSchemaValue decodes a value of type `t` from a JSON value: a JSON
+// string in the command-line format, or a JSON value of the type.
+func decodeSchemaValue(raw json.RawMessage, t reflect.Type) (interface{}, error) {
+	if raw == nil {
+		return reflect.Zero(t).Interface(), nil
+	}
+
+	var s string
+	if t.Kind() != reflect.String && json.Unmarshal(raw, &s) == nil {
+		return convert(s, &Arg{Name: "value", defaultValue: reflect.Zero(t).Interface()})
+	}
+
+	value := reflect.New(t)
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return nil, fmt.Errorf("illegal value %s, must be %v", raw, t)
+	}
+	return value.Elem().Interface(), nil
+}
+
+// MarshalJSON exports the commands, arguments and flags of the registry as a
+// JSON document, in the format of `NewRegistryFromJSON`. Commands and flags
+// are sorted by name.
+//
+// Only the types, defaults, allowed values and descriptions are exported;
+// custom `Value` types are not supported.
+func (registry *Registry) MarshalJSON() ([]byte, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.commands))
+	for name := range registry.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schema := schemaRegistry{Commands: []schemaCommand{}}
+	for _, name := range names {
+		command, err := exportSchemaCommand(registry.commands[name])
+		if err != nil {
+			return nil, fmt.Errorf("command %q: %v", name, err)
+		}
+		schema.Commands = append(schema.Commands, command)
+	}
+	return json.Marshal(schema)
+}
+
+// exportSchemaCommand returns the JSON schema of a command.
+func exportSchemaCommand(commandConfig *CommandConfig) (schemaCommand, error) {
+	command := schemaCommand{
+		Name:            commandConfig.Name,
+		Description:     commandConfig.Description,
+		AbbreviateFlags: commandConfig.AbbreviateFlags,
+		StrictArgs:      commandConfig.StrictArgs,
+	}
+
+	for _, argName := range commandConfig.ArgNames {
+		arg := commandConfig.Args[argName]
+		typeName, defaultValue, allowed, err := exportSchemaDefault(arg)
+		if err != nil {
+			return command, fmt.Errorf("argument %s: %v", argName, err)
+		}
+		command.Args = append(command.Args, schemaArg{
+			Name:        arg.Name,
+			Description: arg.Description,
+			Type:        typeName,
+			Default:     defaultValue,
+			Allowed:     allowed,
+			Variadic:    arg.isVariadic,
+			Optional:    arg.optional,
+		})
+	}
+
+	flagNames := make([]string, 0, len(commandConfig.Flags))
+	for name := range commandConfig.Flags {
+		flagNames = append(flagNames, name)
+	}
+	sort.Strings(flagNames)
+
+	for _, name := range flagNames {
+		flag := commandConfig.Flags[name]
+		typeName, defaultValue, allowed, err := exportSchemaDefault(&flag.Arg)
+		if err != nil {
+			return command, fmt.Errorf("flag %s: %v", name, err)
+		}
+
+		exported := schemaFlag{
+			Name:        flag.Name,
+			Short:       flag.ShortName,
+			Description: flag.Description,
+			Type:        typeName,
+			Kind:        schemaKinds[flag.kind],
+			Default:     defaultValue,
+			Allowed:     allowed,
+		}
+		switch flag.kind {
+		case optionalValueFlag:
+			if exported.Implicit, err = encodeSchemaValue(flag.implicitValue); err != nil {
+				return command, fmt.Errorf("flag %s: %v", name, err)
+			}
+		case listFlag:
+			exported.Separator = flag.separator
+		}
+		command.Flags = append(command.Flags, exported)
+	}
+	return command, nil
+}
+
+// exportSchemaDefault returns the type name, the default value (unless it is
+// the zero value) and the allowed values of an argument or a flag.
+func exportSchemaDefault(a *Arg) (string, json.RawMessage, []json.RawMessage, error) {
+	t := a.elemType()
+	typeName := ""
+	for name, schemaType := range schemaTypes {
+		if t == schemaType {
+			typeName = name
+		}
+	}
+	if typeName == "" {
+		return "", nil, nil, fmt.Errorf("type %v is not supported", t)
+	}
+
+	v := reflect.ValueOf(a.defaultValue)
+	switch {
+	case v.Kind() == reflect.Map:
+		if v.Len() == 0 {
+			return typeName, nil, nil, nil
+		}
+		entries := make(map[string]json.RawMessage)
+		iter := v.MapRange()
+		for iter.Next() {
+			raw, err := encodeSchemaValue(iter.Value().Interface())
+			if err != nil {
+				return "", nil, nil, err
+			}
+			entries[iter.Key().String()] = raw
+		}
+		raw, err := json.Marshal(entries)
+		return typeName, raw, nil, err
+	case isList(v.Type()):
+		allowed := make([]json.RawMessage, v.Len())
+		for i := range allowed {
+			var err error
+			if allowed[i], err = encodeSchemaValue(v.Index(i).Interface()); err != nil {
+				return "", nil, nil, err
+			}
+		}
+		return typeName, nil, allowed, nil
+	case v.IsZero():
+		return typeName, nil, nil, nil
+	}
+
+	raw, err := encodeSchemaValue(a.defaultValue)
+	return typeName, raw, nil, err
+}
+
+// encodeSchemaValue encodes a value as a JSON number or boolean for numbers
+// and booleans, and as a JSON string in the command-line format otherwise.
+func encodeSchemaValue(value interface{}) (json.RawMessage, error) {
+	t := reflect.TypeOf(value)
+	if t != nil && t.PkgPath() == "" && (isNumber(t) || t.Kind() == reflect.Bool || t.Kind() == reflect.String) {
+		return json.Marshal(value)
+	}
+	if v, ok := value.(time.Time); ok {
+		return json.Marshal(v.Format(time.RFC3339Nano))
+	}
+	return json.Marshal(formatValue(value))
+}
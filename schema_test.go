@@ -0,0 +1,128 @@
+package clapper
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+const testSchema = `{
+  "commands": [
+    {
+      "name": "",
+      "description": "Root command",
+      "args": [{"name": "output", "description": "Output file"}],
+      "flags": [
+        {"name": "force", "short": "f", "type": "bool"},
+        {"name": "dir", "default": "/var/users"}
+      ]
+    },
+    {
+      "name": "info",
+      "strictArgs": true,
+      "args": [
+        {"name": "category", "allowed": ["manager", "student"]},
+        {"name": "username", "optional": true},
+        {"name": "subjects", "variadic": true}
+      ],
+      "flags": [
+        {"name": "verbose", "short": "v", "type": "bool"},
+        {"name": "timeout", "type": "duration", "default": "30s"},
+        {"name": "retries", "type": "uint8", "default": 3},
+        {"name": "cache", "type": "bytesize", "allowed": ["1GiB", 2147483648]},
+        {"name": "bind", "type": "addr", "default": "127.0.0.1"},
+        {"name": "since", "type": "time", "default": "2026-10-01T00:00:00Z"},
+        {"name": "color", "kind": "optional", "allowed": ["always", "never"], "implicit": "always"},
+        {"name": "hosts", "kind": "list", "separator": ";"},
+        {"name": "label", "kind": "map", "default": {"tier": "web"}},
+        {"name": "limit", "type": "int", "kind": "map"}
+      ]
+    }
+  ]
+}`
+
+// test defining a registry with a JSON document
+func TestSchema(t *testing.T) {
+	reg, err := NewRegistryFromJSON([]byte(testSchema))
+	assertNoError(t, err)
+
+	root := reg.Command("")
+	assertEqual(t, "Root command", root.Description)
+	assertEqual(t, "Output file", root.Args["output"].Description)
+
+	info := reg.Command("info")
+	assertEqual(t, true, info.StrictArgs)
+	assertEqual(t, []string{"manager", "student"}, info.Args["category"].defaultValue)
+	assertEqual(t, true, info.Args["username"].optional)
+	assertEqual(t, true, info.Args["subjects"].isVariadic)
+	assertEqual(t, []ByteSize{1 << 30, 2 << 30}, info.Flags["cache"].defaultValue)
+	assertEqual(t, map[string]string{"tier": "web"}, info.Flags["label"].defaultValue)
+	assertEqual(t, map[string]int{}, info.Flags["limit"].defaultValue)
+
+	cmd, err := reg.Parse([]string{"info", "student", "--color", "--hosts", "a;b", "--limit", "cpu=2", "-v"})
+	assertNoError(t, err)
+	assertEqual(t, "student", cmd.Args["category"].AsString())
+	assertEqual(t, 30*time.Second, cmd.Flags["timeout"].AsDuration())
+	assertEqual(t, uint8(3), cmd.Flags["retries"].AsUint8())
+	assertEqual(t, netip.MustParseAddr("127.0.0.1"), cmd.Flags["bind"].AsAddr())
+	assertEqual(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), cmd.Flags["since"].AsTime())
+	assertEqual(t, "always", cmd.Flags["color"].AsString())
+	assertEqual(t, []string{"a", "b"}, cmd.Flags["hosts"].AsStrings())
+	assertEqual(t, map[string]int{"cpu": 2}, cmd.Flags["limit"].value)
+	assertEqual(t, true, cmd.Flags["verbose"].AsBool())
+
+	// the exported document defines the same registry
+	data, err := json.Marshal(reg)
+	assertNoError(t, err)
+	again, err := NewRegistryFromJSON(data)
+	assertNoError(t, err)
+	exported, err := again.MarshalJSON()
+	assertNoError(t, err)
+	assertEqual(t, string(data), string(exported))
+
+	var schema schemaRegistry
+	assertNoError(t, json.Unmarshal(data, &schema))
+	assertEqual(t, 2, len(schema.Commands))
+	flags := map[string]schemaFlag{}
+	for _, flag := range schema.Commands[1].Flags {
+		flags[flag.Name] = flag
+	}
+	assertEqual(t, `"30s"`, string(flags["timeout"].Default))
+	assertEqual(t, `3`, string(flags["retries"].Default))
+	assertEqual(t, `"2026-10-01T00:00:00Z"`, string(flags["since"].Default))
+	assertEqual(t, `{"tier":"web"}`, string(flags["label"].Default))
+	assertEqual(t, "list", flags["hosts"].Kind)
+	assertEqual(t, ";", flags["hosts"].Separator)
+	assertEqual(t, `"always"`, string(flags["color"].Implicit))
+	assertEqual(t, 2, len(flags["cache"].Allowed))
+	assertEqual(t, `"2GiB"`, string(flags["cache"].Allowed[1]))
+}
+
+// test invalid JSON documents
+func TestSchemaErrors(t *testing.T) {
+	invalid := []string{
+		`{"commands": [{"name": "a"}, {"name": "a"}]}`,
+		`{"commands": [{"name": "a", "args": [{"name": "x"}, {"name": "x"}]}]}`,
+		`{"commands": [{"name": "a", "args": [{"name": "x", "type": "complex"}]}]}`,
+		`{"commands": [{"name": "a", "args": [{"name": "x", "type": "int", "default": "ten"}]}]}`,
+		`{"commands": [{"name": "a", "args": [{"name": "x", "default": "a", "allowed": ["a", "b"]}]}]}`,
+		`{"commands": [{"name": "a", "args": [{"name": "x", "variadic": true}, {"name": "y", "variadic": true}]}]}`,
+		`{"commands": [{"name": "a", "flags": [{"name": "x", "kind": "set"}]}]}`,
+		`{"commands": [{"name": "a", "flags": [{"name": "x", "type": "bool", "kind": "list"}]}]}`,
+		`{"commands": [{"name": "a", "flags": [{"name": "x", "kind": "map", "default": []}]}]}`,
+		`{"commands": [{"name": "a", "unknown": true}]}`,
+		`{"commands": `,
+	}
+	for _, data := range invalid {
+		_, err := NewRegistryFromJSON([]byte(data))
+		assertError(t, err, "%s", data)
+	}
+
+	// custom values can not be exported
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddFlag("level", "", new(logLevel))
+	_, err := json.Marshal(reg)
+	assertError(t, err)
+}
@@ -0,0 +1,192 @@
+package clapper
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayouts are the layouts (see `time.Parse`) of time values, tried
+// in order, for arguments without layouts of their own (see `Arg.WithTimeLayouts`).
+// Values of layouts without a date, like "15:04", are times of the current day.
+var DefaultTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// reflect types of the time types
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// units of durations, in addition to the units of `time.ParseDuration`
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseTime parses the time value `s` of the argument `a`. It can be:
+//
+//   - a time matching one of the layouts of the argument (or the `DefaultTimeLayouts`)
+//   - `now`, `today`, `yesterday` or `tomorrow` (the last three at midnight)
+//   - a duration relative to now, like `-2h` or `+1d12h`
+//   - one of the above followed by a relative duration, like
+//     `2026-10-01T00:00Z+3d` or `yesterday-2h`
+//
+// Relative times are evaluated against the clock of the argument, and times
+// without a time zone are in the location of the argument (UTC by default).
+func parseTime(s string, a *Arg) (time.Time, error) {
+	if t, ok := a.parseBaseTime(s); ok {
+		return t, nil
+	}
+
+	// a duration relative to now
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		if d, err := parseDuration(s); err == nil {
+			return a.clock().Add(d), nil
+		}
+	}
+
+	// a time followed by a relative duration, split at the last possible sign
+	for i := len(s) - 1; i > 0; i-- {
+		if s[i] != '+' && s[i] != '-' {
+			continue
+		}
+		d, err := parseDuration(s[i:])
+		if err != nil {
+			continue
+		}
+		if t, ok := a.parseBaseTime(s[:i]); ok {
+			return t.Add(d), nil
+		}
+	}
+
+	return time.Time{}, BadArgument{Arg: a, Message: fmt.Sprintf("illegal time %s, must match one of the layouts %s, or be a relative time", s, strings.Join(a.layouts(), ", "))}
+}
+
+// parseBaseTime parses a time value matching one of the layouts of the
+// argument, or a named day, without a relative duration.
+func (a *Arg) parseBaseTime(s string) (time.Time, bool) {
+	location := a.location
+	if location == nil {
+		location = time.UTC
+	}
+	now := a.clock().In(location)
+	year, month, day := now.Date()
+
+	switch s {
+	case "now":
+		return now, true
+	case "today":
+		return time.Date(year, month, day, 0, 0, 0, 0, location), true
+	case "yesterday":
+		return time.Date(year, month, day-1, 0, 0, 0, 0, location), true
+	case "tomorrow":
+		return time.Date(year, month, day+1, 0, 0, 0, 0, location), true
+	}
+
+	for _, layout := range a.layouts() {
+		t, err := time.ParseInLocation(layout, s, location)
+		if err != nil {
+			continue
+		}
+
+		// a time without a date is a time of the current day
+		if t.Year() == 0 {
+			t = time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location)
+		}
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// layouts returns the layouts of the time values of the argument
+func (a *Arg) layouts() []string {
+	if len(a.timeLayouts) > 0 {
+		return a.timeLayouts
+	}
+	return DefaultTimeLayouts
+}
+
+// clock returns the current time of the clock of the argument
+func (a *Arg) clock() time.Time {
+	if a.now == nil {
+		return time.Now()
+	}
+	return a.now()
+}
+
+// parseDuration parses a duration like `time.ParseDuration`, but also accepts
+// days (`d`) and weeks (`w`) of 24 hours and 7 days, alone or in compound
+// durations like `1d12h` or `2w3d`.
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	illegal := fmt.Errorf("time: invalid duration %q", s)
+
+	// optional sign
+	value := s
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		if value[0] == '-' {
+			sign = -1
+		}
+		value = value[1:]
+	}
+	if value == "" {
+		return 0, illegal
+	}
+
+	var total time.Duration
+	for value != "" {
+		// number (with an optional fraction)
+		n := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if n <= 0 {
+			return 0, illegal
+		}
+		number := value[:n]
+		value = value[n:]
+
+		// unit
+		u := strings.IndexFunc(value, func(r rune) bool { return (r >= '0' && r <= '9') || r == '.' })
+		if u < 0 {
+			u = len(value)
+		}
+		unit := value[:u]
+		value = value[u:]
+
+		var d time.Duration
+		if size, ok := durationUnits[unit]; ok {
+			f, err := strconv.ParseFloat(number, 64)
+			if err != nil || f*float64(size) > math.MaxInt64 {
+				return 0, illegal
+			}
+			d = time.Duration(f * float64(size))
+		} else {
+			var err error
+			if d, err = time.ParseDuration(number + unit); err != nil {
+				return 0, illegal
+			}
+		}
+
+		if total > math.MaxInt64-d {
+			return 0, illegal
+		}
+		total += d
+	}
+
+	return sign * total, nil
+}
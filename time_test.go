@@ -0,0 +1,171 @@
+package clapper
+
+import (
+	"testing"
+	"time"
+)
+
+// test the time types and layouts
+func TestTimeValues(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		paris = time.FixedZone("CET", 3600)
+	}
+	now := time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		layouts  []string
+		location *time.Location
+		expected time.Time
+		err      bool
+	}{
+		{"2026-10-01T12:30:00+02:00", nil, nil, time.Date(2026, 10, 1, 10, 30, 0, 0, time.UTC), false},
+		{"2026-10-01 12:30", nil, nil, time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC), false},
+		{"2026-10-01T12:30:15", nil, nil, time.Date(2026, 10, 1, 12, 30, 15, 0, time.UTC), false},
+		{"2026-10-01", nil, nil, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2026-10-01", nil, paris, time.Date(2026, 10, 1, 0, 0, 0, 0, paris), false},
+		{"23:15", nil, nil, time.Date(2026, 10, 18, 23, 15, 0, 0, time.UTC), false},
+		{"01/10/2026", []string{"02/01/2006"}, nil, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2026-10-01", []string{"02/01/2006"}, nil, time.Time{}, true},
+		{"tomorrow", nil, nil, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		reg.SetClock(func() time.Time { return now })
+		root, _ := reg.Register("")
+		since, _ := root.AddFlag("since", "", time.Time{})
+		if test.layouts != nil {
+			since.WithTimeLayouts(test.layouts...)
+		}
+		if test.location != nil {
+			since.WithLocation(test.location)
+		}
+
+		cmd, err := reg.Parse([]string{"--since", test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, true, test.expected.Equal(cmd.Flags["since"].AsTime()), " (%s: %v)", test.value, cmd.Flags["since"].AsTime())
+	}
+
+	// int64 and struct types are not time types
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddFlag("id", "", int64(0))
+	root.AddFlag("timeout", "", time.Duration(0))
+	cmd, err := reg.Parse([]string{"--id", "60", "--timeout", "60s"})
+	assertNoError(t, err)
+	assertEqual(t, int64(60), cmd.Flags["id"].value)
+	assertEqual(t, time.Minute, cmd.Flags["timeout"].value)
+	_, err = reg.Parse([]string{"--id", "60s"})
+	assertError(t, err)
+	root.AddFlag("point", "", struct{ X, Y int }{})
+	_, err = reg.Parse([]string{"--point", "2026-10-01"})
+	assertError(t, err)
+}
+
+// test durations with days and weeks
+func TestDurations(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"3d", 72 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w3d4h5m", (17*24+4)*time.Hour + 5*time.Minute, false},
+		{"-1d", -24 * time.Hour, false},
+		{"+1w", 7 * 24 * time.Hour, false},
+		{"1d-2h", 0, true},
+		{"d", 0, true},
+		{"3y", 0, true},
+		{"100000000w", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := parseDuration(test.value)
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, test.expected, d, " (%s)", test.value)
+	}
+}
+
+// test relative times
+func TestRelativeTimes(t *testing.T) {
+	now := time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Time
+		err      bool
+	}{
+		{"now", now, false},
+		{"today", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), false},
+		{"tomorrow", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), false},
+		{"-2h", now.Add(-2 * time.Hour), false},
+		{"+1d12h", now.Add(36 * time.Hour), false},
+		{"yesterday+12h", time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), false},
+		{"now-1w", now.Add(-7 * 24 * time.Hour), false},
+		{"2026-10-01T00:00Z+3d", time.Date(2026, 10, 4, 0, 0, 0, 0, time.UTC), false},
+		{"2026-10-01-2h", time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC), false},
+		{"2026-10-01T00:00:00+02:00", time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC), false},
+		{"2026-10-01T00:00:00+02:00+1h", time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC), false},
+		{"noon", time.Time{}, true},
+		{"now+", time.Time{}, true},
+	}
+
+	reg := NewRegistry()
+	reg.SetClock(func() time.Time { return now })
+	root, _ := reg.Register("")
+	root.AddFlag("since", "s", time.Time{})
+	root.AddFlag("until", "", time.Time{})
+	root.AddArg("at", time.Time{})
+
+	for _, test := range tests {
+		args := []string{"--since", test.value, "--until=" + test.value, test.value}
+
+		cmd, err := reg.Parse(args)
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, true, test.expected.Equal(cmd.Flags["since"].AsTime()), " (%s: %v)", test.value, cmd.Flags["since"].AsTime())
+		assertEqual(t, true, test.expected.Equal(cmd.Flags["until"].AsTime()), " (%s: %v)", test.value, cmd.Flags["until"].AsTime())
+		assertEqual(t, true, test.expected.Equal(cmd.Args["at"].AsTime()), " (%s: %v)", test.value, cmd.Args["at"].AsTime())
+	}
+
+	// a positional value starting with a sign is a relative time, like after `--`
+	cmd, err := reg.Parse([]string{"-2h"})
+	assertNoError(t, err)
+	assertEqual(t, true, now.Add(-2*time.Hour).Equal(cmd.Args["at"].AsTime()))
+	cmd, err = reg.Parse([]string{"-s", "now", "--", "-1d"})
+	assertNoError(t, err)
+	assertEqual(t, true, now.Add(-24*time.Hour).Equal(cmd.Args["at"].AsTime()))
+	assertEqual(t, []int{3}, cmd.Args["at"].Positions())
+
+	// a value starting with a sign is taken by a short flag too
+	cmd, err = reg.Parse([]string{"-s", "-2h", "--until", "+1d12h"})
+	assertNoError(t, err)
+	assertEqual(t, true, now.Add(-2*time.Hour).Equal(cmd.Flags["since"].AsTime()))
+	assertEqual(t, true, now.Add(36*time.Hour).Equal(cmd.Flags["until"].AsTime()))
+
+	// a registered flag is not the value of the previous flag
+	_, err = reg.Parse([]string{"--since", "--until", "now"})
+	assertError(t, err)
+	_, err = reg.Parse([]string{"--until", "-s", "now"})
+	assertError(t, err)
+}
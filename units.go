@@ -0,0 +1,166 @@
+package clapper
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, like `512MiB` or `1.5G`.
+//
+// Command-line argument values are a decimal number followed by an optional
+// SI (`k`, `M`, `G`, `T`, `P`, `E`, powers of 1000) or IEC (`Ki`, `Mi`, `Gi`,
+// `Ti`, `Pi`, `Ei`, powers of 1024) unit prefix and an optional `B`. Units are
+// not case-sensitive, and the size must be a whole number of bytes.
+type ByteSize uint64
+
+// Percent is a fraction, like `75%` (0.75).
+//
+// Command-line argument values are a decimal number followed by `%`, or a
+// fraction without `%` (`0.75`).
+type Percent float64
+
+// reflect types of the unit types
+var (
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	percentType  = reflect.TypeOf(Percent(0))
+)
+
+// byte size units, largest first
+var byteSizeUnits = []struct {
+	name string
+	size uint64
+}{
+	{"EiB", 1 << 60}, {"EB", 1e18},
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"kB", 1e3},
+	{"B", 1},
+}
+
+// byte size unit prefixes (upper case) and their sizes
+var byteSizePrefixes = map[string]uint64{
+	"":  1,
+	"K": 1e3, "KI": 1 << 10,
+	"M": 1e6, "MI": 1 << 20,
+	"G": 1e9, "GI": 1 << 30,
+	"T": 1e12, "TI": 1 << 40,
+	"P": 1e15, "PI": 1 << 50,
+	"E": 1e18, "EI": 1 << 60,
+}
+
+// String formats the byte size with the largest unit giving at most three decimals, like `1.5GiB`.
+func (b ByteSize) String() string {
+	for _, unit := range byteSizeUnits {
+		if uint64(b) < unit.size {
+			continue
+		}
+
+		r := new(big.Rat).SetFrac(new(big.Int).SetUint64(uint64(b)), new(big.Int).SetUint64(unit.size))
+		if s := r.FloatString(3); r.Cmp(ratString(s)) == 0 {
+			return strings.TrimSuffix(strings.TrimRight(s, "0"), ".") + unit.name
+		}
+	}
+	return fmt.Sprintf("%dB", uint64(b))
+}
+
+// String formats the percentage, like `75%`.
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p)*100, 'g', 12, 64) + "%"
+}
+
+// parseByteSize parses a byte size like `512MiB`, `1.5G` or `100`.
+func parseByteSize(s string) (ByteSize, error) {
+	illegal := fmt.Errorf("invalid byte size %q", s)
+
+	// the number is followed by the unit
+	n := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if n < 0 {
+		n = len(s)
+	}
+	number, unit := s[:n], strings.ToUpper(strings.TrimSpace(s[n:]))
+	if number == "" || strings.Count(number, ".") > 1 {
+		return 0, illegal
+	}
+
+	// the `B` is optional
+	size, ok := byteSizePrefixes[strings.TrimSuffix(unit, "B")]
+	if !ok {
+		return 0, illegal
+	}
+
+	r, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return 0, illegal
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(size)))
+
+	switch {
+	case !r.IsInt():
+		return 0, fmt.Errorf("invalid byte size %q, not a whole number of bytes", s)
+	case !r.Num().IsUint64():
+		return 0, fmt.Errorf("invalid byte size %q, value out of range", s)
+	}
+	return ByteSize(r.Num().Uint64()), nil
+}
+
+// parsePercent parses a percentage like `75%`, or a fraction like `0.75`.
+func parsePercent(s string) (Percent, error) {
+	value, divisor := s, 1.0
+	if strings.HasSuffix(s, "%") {
+		value, divisor = strings.TrimSpace(strings.TrimSuffix(s, "%")), 100
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return Percent(f / divisor), nil
+}
+
+// ratString returns the rational number of the decimal string `s`
+func ratString(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+func (a Arg) AsByteSize() ByteSize {
+	if v, ok := a.value.(ByteSize); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(ByteSize)
+		return v
+	}
+}
+
+func (a Arg) AsPercent() Percent {
+	if v, ok := a.value.(Percent); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.(Percent)
+		return v
+	}
+}
+
+func (a Arg) AsByteSizes() []ByteSize {
+	if v, ok := a.value.([]ByteSize); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]ByteSize)
+		return v
+	}
+}
+
+func (a Arg) AsPercents() []Percent {
+	if v, ok := a.value.([]Percent); ok {
+		return v
+	} else {
+		v, _ = a.defaultValue.([]Percent)
+		return v
+	}
+}
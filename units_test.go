@@ -0,0 +1,118 @@
+package clapper
+
+import (
+	"testing"
+)
+
+// test byte sizes
+func TestByteSizes(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected ByteSize
+		err      bool
+	}{
+		{"100", 100, false},
+		{"100B", 100, false},
+		{"512MiB", 512 << 20, false},
+		{"512mib", 512 << 20, false},
+		{"1.5G", 1500000000, false},
+		{"1.5GB", 1500000000, false},
+		{"1.5Gi", 3 << 29, false},
+		{"10 kB", 10000, false},
+		{"16EiB", 0, true},
+		{"18446744073709551615", 18446744073709551615, false},
+		{"18446744073709551616", 0, true},
+		{"20E", 0, true},
+		{"1.1B", 0, true},
+		{"1.2.3K", 0, true},
+		{"MiB", 0, true},
+		{"12XB", 0, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("max-size", "", ByteSize(0))
+
+		cmd, err := reg.Parse([]string{"--max-size", test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, test.expected, cmd.Flags["max-size"].AsByteSize(), " (%s)", test.value)
+	}
+
+	// formatting
+	formats := map[ByteSize]string{
+		0:          "0B",
+		100:        "100B",
+		1000:       "1kB",
+		1500:       "1.5kB",
+		1024:       "1KiB",
+		512 << 20:  "512MiB",
+		3 << 29:    "1.5GiB",
+		1500000000: "1.5GB",
+		1000001:    "1000.001kB",
+		999:        "999B",
+	}
+	for size, expected := range formats {
+		assertEqual(t, expected, size.String())
+	}
+
+	// allowed values and help text
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	cache, _ := root.AddFlag("cache", "", []ByteSize{1 << 30, 2 << 30})
+	size, _ := root.AddFlag("size", "", ByteSize(64<<20))
+	_, err := reg.Parse([]string{"--cache", "1GiB"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--cache", "1G"})
+	assertError(t, err)
+	assertEqual(t, "1GiB|2GiB", cache.DefaultString())
+	assertEqual(t, "64MiB", size.DefaultString())
+}
+
+// test percentages
+func TestPercents(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Percent
+		err      bool
+	}{
+		{"75%", 0.75, false},
+		{"12.5%", 0.125, false},
+		{"150%", 1.5, false},
+		{"0.75", 0.75, false},
+		{"75 %", 0.75, false},
+		{"%", 0, true},
+		{"a%", 0, true},
+		{"NaN", 0, true},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddFlag("ratio", "", Percent(0))
+
+		cmd, err := reg.Parse([]string{"--ratio", test.value})
+		if test.err {
+			assertError(t, err, "%s", test.value)
+			continue
+		}
+		assertNoError(t, err, "%s", test.value)
+		assertEqual(t, test.expected, cmd.Flags["ratio"].AsPercent(), " (%s)", test.value)
+	}
+
+	assertEqual(t, "75%", Percent(0.75).String())
+	assertEqual(t, "7%", Percent(0.07).String())
+
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	ratio, _ := root.AddFlag("ratio", "", []Percent{0.25, 0.5})
+	_, err := reg.Parse([]string{"--ratio", "50%"})
+	assertNoError(t, err)
+	_, err = reg.Parse([]string{"--ratio", "60%"})
+	assertError(t, err)
+	assertEqual(t, "25%|50%", ratio.DefaultString())
+}
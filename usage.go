@@ -0,0 +1,573 @@
+package clapper
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NewRegistryFromUsage returns a new registry with the commands, arguments and
+// flags of a docopt-style usage text, like:
+//
+//	Usage:
+//	  app info <category> [<username>] [<subjects>...] [-v] [--output=<dir>]
+//	  app ghost
+//
+//	Options:
+//	  -v, --verbose       Print more.
+//	  -o, --output=<dir>  Output directory [default: ./].
+//
+// Every usage line starts with the program name, optionally followed by the
+// name of a sub-command (lines without one belong to the root command), or by
+// alternative sub-commands, like `app (add|rm) <name>`, which is a line of
+// each of them. The patterns can contain:
+//
+//   - positional arguments, like `<name>` or `NAME`, which are string arguments
+//   - flags, like `-v`, `--verbose`, `--output=<dir>` or `--output <dir>`; a
+//     flag takes a value if it is written with one, in the pattern or in the
+//     options section, and is a boolean flag otherwise
+//   - `...` after an element, which makes an argument variadic and a flag a
+//     list flag
+//   - optional groups `[...]`, required groups `(...)` and alternatives `a | b`
+//   - `[options]`, which stands for any of the flags of the options section
+//
+// The lines of a command are alternatives. Arguments which are not required by
+// all the alternatives are optional (see `Arg.Optional`). The commands take no
+// surplus arguments (see `CommandConfig.StrictArgs`), and a validator of each
+// command (see `CommandConfig.AddValidator`) checks that the provided arguments
+// and flags match one of the alternatives, so `Parse` returns a `BadCommand`
+// error if they don't.
+func NewRegistryFromUsage(usage string) (*Registry, error) {
+	lines, options, err := splitUsage(usage)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse the usage lines, grouping them by command
+	undescribed := make(map[string]*usageOption)
+	var commands []*usageCommand
+	byName := make(map[string]*usageCommand)
+	var program string
+	for _, line := range lines {
+		tokens := tokenizeUsage(line)
+		if program == "" {
+			program = tokens[0]
+		} else if tokens[0] != program {
+			return nil, fmt.Errorf("usage line %q must start with the program name %s", line, program)
+		}
+		names, tokens := splitCommandNames(tokens[1:])
+
+		// a line with alternative commands is a line of each of them
+		for _, name := range names {
+			command := byName[name]
+			if command == nil {
+				command = &usageCommand{name: name}
+				byName[name] = command
+				commands = append(commands, command)
+			}
+			command.lines = append(command.lines, line)
+
+			p := usageParser{tokens: tokens, options: options, undescribed: undescribed, command: command}
+			alternatives, err := p.parseExpr()
+			if err == nil && len(p.tokens) > 0 {
+				err = fmt.Errorf("unexpected %s", p.tokens[0])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("usage line %q: %v", line, err)
+			}
+			command.alternatives = append(command.alternatives, alternatives...)
+		}
+	}
+
+	registry := NewRegistry()
+	for _, command := range commands {
+		if err := command.register(registry); err != nil {
+			return nil, err
+		}
+	}
+	if err := registry.validate(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// splitCommandNames returns the names of the commands of the tokens of a usage
+// line following the program name, and the tokens of their pattern. The names
+// are a sub-command name, a required group of alternative sub-command names,
+// like `(add|rm)`, or "" (the root command) if the line has neither.
+func splitCommandNames(tokens []string) ([]string, []string) {
+	if len(tokens) > 0 && usageCommandName.MatchString(tokens[0]) {
+		return tokens[:1], tokens[1:]
+	}
+	if len(tokens) == 0 || tokens[0] != "(" {
+		return []string{""}, tokens
+	}
+
+	var names []string
+	for i := 1; i+1 < len(tokens) && usageCommandName.MatchString(tokens[i]); i += 2 {
+		names = append(names, tokens[i])
+		if tokens[i+1] == ")" {
+			return names, tokens[i+2:]
+		}
+		if tokens[i+1] != "|" {
+			break
+		}
+	}
+	return []string{""}, tokens
+}
+
+// a sub-command name in a usage line
+var usageCommandName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// a positional argument in a usage line, like `<name>` or `NAME`
+var usageArgName = regexp.MustCompile(`^(<[^<>]+>|[A-Z][A-Z0-9_-]*)$`)
+
+// the default value in the description of an option
+var usageDefault = regexp.MustCompile(`(?i)\[default:\s*([^\]]*)\]`)
+
+// usageCommand holds the arguments, flags and alternatives of a command of a usage text.
+type usageCommand struct {
+	name         string
+	lines        []string
+	args         []*usageArg
+	flags        []*usageOption
+	alternatives [][]usageItem
+}
+
+// usageArg is a positional argument of a usage text.
+type usageArg struct {
+	name     string
+	variadic bool
+}
+
+// usageOption is a flag of a usage text.
+type usageOption struct {
+	long, short  string
+	takesValue   bool
+	defaultValue string
+	repeated     bool
+}
+
+// name returns the name of the flag to register.
+func (o *usageOption) name() string {
+	if o.long != "" {
+		return o.long
+	}
+	return o.short
+}
+
+// usageItem is an argument or a flag in an alternative of a command.
+type usageItem struct {
+	arg      *usageArg
+	option   *usageOption
+	optional bool
+}
+
+// splitUsage returns the usage lines of a usage text, and the options of its
+// options section, by `-s` and `--long` names.
+func splitUsage(usage string) ([]string, map[string]*usageOption, error) {
+	var lines []string
+	options := make(map[string]*usageOption)
+
+	section := ""
+	for _, line := range strings.Split(usage, "\n") {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case strings.HasPrefix(lower, "usage:"):
+			section = "usage"
+			trimmed = strings.TrimSpace(trimmed[len("usage:"):])
+		case strings.HasPrefix(lower, "options:"):
+			section = "options"
+			continue
+		case trimmed == "":
+			section = ""
+			continue
+		}
+
+		switch {
+		case section == "usage" && trimmed != "":
+			lines = append(lines, trimmed)
+		case section == "options" && strings.HasPrefix(trimmed, "-"):
+			if err := parseUsageOption(trimmed, options); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, nil, fmt.Errorf("usage: no usage lines found")
+	}
+	return lines, options, nil
+}
+
+// parseUsageOption parses an option line, like `-o, --output=<dir>  Output
+// directory [default: ./]`, into `options`.
+func parseUsageOption(line string, options map[string]*usageOption) error {
+	spec, description := line, ""
+	if i := strings.Index(line, "  "); i >= 0 {
+		spec, description = line[:i], line[i:]
+	}
+
+	option := &usageOption{}
+	for _, word := range strings.Fields(strings.NewReplacer(",", " ", "=", " ").Replace(spec)) {
+		switch {
+		case strings.HasPrefix(word, "--"):
+			option.long = word[2:]
+		case strings.HasPrefix(word, "-") && len(word) == 2:
+			option.short = word[1:]
+		case usageArgName.MatchString(word):
+			option.takesValue = true
+		default:
+			return fmt.Errorf("usage: illegal option %q", line)
+		}
+	}
+	if m := usageDefault.FindStringSubmatch(description); m != nil {
+		option.defaultValue = strings.TrimSpace(m[1])
+	}
+
+	for _, key := range option.keys() {
+		if _, ok := options[key]; ok {
+			return fmt.Errorf("usage: option %s is described twice", key)
+		}
+		options[key] = option
+	}
+	return nil
+}
+
+// keys returns the names of the option, like `-o` and `--output`.
+func (o *usageOption) keys() []string {
+	var keys []string
+	if o.short != "" {
+		keys = append(keys, "-"+o.short)
+	}
+	if o.long != "" {
+		keys = append(keys, "--"+o.long)
+	}
+	return keys
+}
+
+// tokenizeUsage splits a usage line into words, parentheses, brackets, `|`
+// and `...`.
+func tokenizeUsage(line string) []string {
+	var tokens []string
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == ' ' || line[i] == '\t':
+			i++
+		case strings.HasPrefix(line[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.ContainsRune("()[]|", rune(line[i])):
+			tokens = append(tokens, line[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(line) && !strings.ContainsRune(" \t()[]|", rune(line[j])) && !strings.HasPrefix(line[j:], "...") {
+				j++
+			}
+			tokens = append(tokens, line[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// usageParser parses the pattern of a usage line into its alternatives, each
+// alternative being the list of the arguments and flags it takes.
+type usageParser struct {
+	tokens []string
+
+	// options of the options section, and options only used in the usage
+	// lines, by `-s` and `--long` names
+	options, undescribed map[string]*usageOption
+
+	command *usageCommand
+}
+
+// peek returns the next token, or "" at the end of the line.
+func (p *usageParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+// next removes and returns the next token.
+func (p *usageParser) next() string {
+	tok := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+// parseExpr parses alternatives separated by `|`.
+func (p *usageParser) parseExpr() ([][]usageItem, error) {
+	alternatives, err := p.parseSeq()
+	for err == nil && p.peek() == "|" {
+		p.next()
+		var more [][]usageItem
+		more, err = p.parseSeq()
+		alternatives = append(alternatives, more...)
+	}
+	return alternatives, err
+}
+
+// parseSeq parses a sequence of elements, all of which are taken.
+func (p *usageParser) parseSeq() ([][]usageItem, error) {
+	alternatives := [][]usageItem{{}}
+	for {
+		switch p.peek() {
+		case "", ")", "]", "|":
+			return alternatives, nil
+		}
+
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+
+		// every alternative of the sequence so far, followed by every
+		// alternative of the element
+		var product [][]usageItem
+		for _, a := range alternatives {
+			for _, b := range atom {
+				product = append(product, append(append([]usageItem{}, a...), b...))
+			}
+		}
+		alternatives = product
+	}
+}
+
+// parseAtom parses a group, an argument or a flag, optionally followed by `...`.
+func (p *usageParser) parseAtom() ([][]usageItem, error) {
+	var alternatives [][]usageItem
+	var err error
+
+	tok := p.next()
+	switch {
+	case tok == "[" && len(p.tokens) > 1 && p.tokens[0] == "options" && p.tokens[1] == "]":
+		p.tokens = p.tokens[2:]
+		alternatives = [][]usageItem{p.allOptions()}
+	case tok == "(" || tok == "[":
+		closing := map[string]string{"(": ")", "[": "]"}[tok]
+		if alternatives, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if p.next() != closing {
+			return nil, fmt.Errorf("missing %s", closing)
+		}
+		if tok == "[" {
+			for _, alternative := range alternatives {
+				for i := range alternative {
+					alternative[i].optional = true
+				}
+			}
+		}
+	case usageArgName.MatchString(tok):
+		alternatives = [][]usageItem{{{arg: p.arg(strings.Trim(tok, "<>"))}}}
+	case strings.HasPrefix(tok, "--") && len(tok) > 2:
+		name, value := tok[2:], ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		}
+		option := p.option("--"+name, &usageOption{long: name}, value != "")
+		alternatives = [][]usageItem{{{option: option}}}
+	case strings.HasPrefix(tok, "-") && len(tok) > 1 && !strings.HasPrefix(tok, "--"):
+		// a cluster of short flags, like `-abc`
+		var items []usageItem
+		for _, short := range tok[1:] {
+			items = append(items, usageItem{option: p.option("-"+string(short), &usageOption{short: string(short)}, false)})
+		}
+		alternatives = [][]usageItem{items}
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of line")
+	default:
+		return nil, fmt.Errorf("unexpected %s", tok)
+	}
+
+	if p.peek() == "..." {
+		p.next()
+		for _, alternative := range alternatives {
+			for _, item := range alternative {
+				if item.arg != nil {
+					item.arg.variadic = true
+				} else {
+					item.option.repeated = true
+				}
+			}
+		}
+	}
+	return alternatives, nil
+}
+
+// arg returns the positional argument `name` of the command, declaring it if needed.
+func (p *usageParser) arg(name string) *usageArg {
+	for _, arg := range p.command.args {
+		if arg.name == name {
+			return arg
+		}
+	}
+	arg := &usageArg{name: name}
+	p.command.args = append(p.command.args, arg)
+	return arg
+}
+
+// option returns the option `key` (like `-o` or `--output`) of the options
+// section, or `option` if it is not described there, and declares it as a
+// flag of the command. If `withValue` is true, or the option takes a value
+// and the next token is a placeholder of the value, the option takes a value.
+func (p *usageParser) option(key string, option *usageOption, withValue bool) *usageOption {
+	if described, ok := p.options[key]; ok {
+		option = described
+	} else if used, ok := p.undescribed[key]; ok {
+		option = used
+	} else {
+		p.undescribed[key] = option
+	}
+
+	if withValue {
+		option.takesValue = true
+	} else if option.takesValue && usageArgName.MatchString(p.peek()) {
+		p.next()
+	}
+
+	p.declare(option)
+	return option
+}
+
+// allOptions returns the optional items of all the options of the options section.
+func (p *usageParser) allOptions() []usageItem {
+	var items []usageItem
+	seen := make(map[*usageOption]bool)
+	for _, key := range sortedKeys(p.options) {
+		option := p.options[key]
+		if !seen[option] {
+			seen[option] = true
+			p.declare(option)
+			items = append(items, usageItem{option: option, optional: true})
+		}
+	}
+	return items
+}
+
+// declare declares the option as a flag of the command.
+func (p *usageParser) declare(option *usageOption) {
+	for _, flag := range p.command.flags {
+		if flag == option {
+			return
+		}
+	}
+	p.command.flags = append(p.command.flags, option)
+}
+
+// register registers the command, its arguments and flags, and a validator
+// checking that the provided arguments and flags match an alternative.
+func (command *usageCommand) register(registry *Registry) error {
+	commandConfig, _ := registry.Register(command.name)
+	commandConfig.StrictArgs = true
+
+	// registered names of the arguments and flags
+	names := make(map[interface{}]string)
+
+	for _, arg := range command.args {
+		name := arg.name
+		if arg.variadic {
+			name += "..."
+		}
+		registered := commandConfig.AddArg(name, "")
+		if !command.requires(arg) {
+			registered.Optional()
+		}
+		names[arg] = registered.Name
+	}
+
+	for _, option := range command.flags {
+		var flag *Flag
+		var err error
+		switch {
+		case !option.takesValue:
+			flag, err = commandConfig.AddFlag(option.name(), option.short, false)
+		case option.repeated:
+			flag, err = commandConfig.AddListFlag(option.name(), option.short, "", "")
+		default:
+			flag, err = commandConfig.AddFlag(option.name(), option.short, option.defaultValue)
+		}
+		if err != nil {
+			return fmt.Errorf("usage: flag %s: %v", option.name(), err)
+		}
+		names[option] = flag.Name
+	}
+
+	alternatives := command.alternatives
+	lines := command.lines
+	return commandConfig.AddValidator(func(result *ParseResult) error {
+		for _, alternative := range alternatives {
+			if matchesUsage(result, alternative, names) {
+				return nil
+			}
+		}
+		return fmt.Errorf("arguments must match %s", strings.Join(lines, " | "))
+	})
+}
+
+// requires tells if all the alternatives of the command require the argument.
+func (command *usageCommand) requires(arg *usageArg) bool {
+	for _, alternative := range command.alternatives {
+		required := false
+		for _, item := range alternative {
+			if item.arg == arg && !item.optional {
+				required = true
+			}
+		}
+		if !required {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesUsage tells if the provided arguments and flags of the result are
+// the ones of the alternative: all of its required ones, and no others.
+func matchesUsage(result *ParseResult, alternative []usageItem, names map[interface{}]string) bool {
+	allowed := make(map[string]bool)
+	for _, item := range alternative {
+		var key string
+		var provided bool
+		if item.arg != nil {
+			key = "arg " + names[item.arg]
+			provided = result.Args[names[item.arg]].IsSet()
+		} else {
+			key = "flag " + names[item.option]
+			provided = result.Flags[names[item.option]].IsSet()
+		}
+		if !provided && !item.optional {
+			return false
+		}
+		allowed[key] = true
+	}
+
+	for name, arg := range result.Args {
+		if arg.IsSet() && !allowed["arg "+name] {
+			return false
+		}
+	}
+	for name, flag := range result.Flags {
+		if flag.IsSet() && !allowed["flag "+name] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of the options, sorted.
+func sortedKeys(options map[string]*usageOption) []string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
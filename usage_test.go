@@ -0,0 +1,169 @@
+package clapper
+
+import (
+	"errors"
+	"testing"
+)
+
+// test registering commands from a usage text
+func TestUsage(t *testing.T) {
+	reg, err := NewRegistryFromUsage(
+		"Usage: app info <category> [<username>] [<subjects>...] [-v] [--output=<dir>]\n" +
+			"       app copy <src>... <dest> [options]\n" +
+			"       app serve (--json | --yaml) [--port <port>]\n" +
+			"       app ghost\n" +
+			"\n" +
+			"Options:\n" +
+			"  -v, --verbose       Print more.\n" +
+			"  -o, --output=<dir>  Output directory [default: ./].\n" +
+			"  -f, --force         Overwrite files.\n" +
+			"  --port=<port>       Port [default: 8080].\n")
+	assertNoError(t, err)
+
+	info := reg.Command("info")
+	assertEqual(t, []string{"category", "username", "subjects"}, info.ArgNames)
+	assertEqual(t, false, info.Args["category"].optional)
+	assertEqual(t, true, info.Args["username"].optional)
+	assertEqual(t, true, info.Args["subjects"].isVariadic)
+	assertEqual(t, "v", info.Flags["verbose"].ShortName)
+	assertEqual(t, "./", info.Flags["output"].defaultValue)
+	assertEqual(t, false, info.Flags["verbose"].defaultValue)
+	assertEqual(t, true, info.StrictArgs)
+
+	tests := []struct {
+		args []string
+		err  bool
+	}{
+		{[]string{"info", "student"}, false},
+		{[]string{"info", "student", "thatisuday", "math", "science", "-v", "-o", "out"}, false},
+		{[]string{"info"}, true},
+		{[]string{"info", "student", "--force"}, true},
+		{[]string{"copy", "a", "b", "c", "-f", "--port", "80"}, false},
+		{[]string{"copy", "a"}, true},
+		{[]string{"serve", "--json"}, false},
+		{[]string{"serve", "--yaml", "--port=8081"}, false},
+		{[]string{"serve"}, true},
+		{[]string{"serve", "--json", "--yaml"}, true},
+		{[]string{"serve", "--json", "extra"}, true},
+		{[]string{"ghost"}, false},
+		{[]string{"ghost", "-v"}, true},
+	}
+
+	for _, test := range tests {
+		_, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+	}
+
+	cmd, err := reg.Parse([]string{"copy", "a", "b", "c"})
+	assertNoError(t, err)
+	assertEqual(t, []string{"a", "b"}, cmd.Args["src"].AsStrings())
+	assertEqual(t, "c", cmd.Args["dest"].AsString())
+	assertEqual(t, "8080", cmd.Flags["port"].AsString())
+
+	_, err = reg.Parse([]string{"serve", "--json", "--yaml"})
+	var badCommand BadCommand
+	assertEqual(t, true, errors.As(err, &badCommand))
+	assertEqual(t, "serve", badCommand.Command.Name)
+}
+
+// test alternatives and repeated elements
+func TestUsageAlternatives(t *testing.T) {
+	reg, err := NewRegistryFromUsage(`
+Usage:
+  tool FILE [--tag=<tag>...]
+  tool (-a | -b -c) FILE
+  tool -x...
+`)
+	assertNoError(t, err)
+
+	tests := []struct {
+		args []string
+		err  bool
+	}{
+		{[]string{"f"}, false},
+		{[]string{"f", "--tag", "a", "--tag", "b"}, false},
+		{[]string{"-a", "f"}, false},
+		{[]string{"-b", "-c", "f"}, false},
+		{[]string{"-bc", "f"}, false},
+		{[]string{"-b", "f"}, true},
+		{[]string{"-a", "-b", "-c", "f"}, true},
+		{[]string{"-a", "f", "--tag", "a"}, true},
+		{[]string{"-x"}, false},
+		{[]string{"-x", "f"}, true},
+		{[]string{}, true},
+	}
+
+	for _, test := range tests {
+		_, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+	}
+
+	cmd, err := reg.Parse([]string{"f", "--tag", "a", "--tag", "b"})
+	assertNoError(t, err)
+	assertEqual(t, "f", cmd.Args["FILE"].AsString())
+	assertEqual(t, []string{"a", "b"}, cmd.Flags["tag"].AsStrings())
+
+	// alternative commands in a line are a line of each command
+	reg, err = NewRegistryFromUsage(`
+Usage:
+  app (add|rm) <name> [-f]
+  app rm --all
+  app list
+`)
+	assertNoError(t, err)
+	assertEqual(t, []string{"name"}, reg.Command("add").ArgNames)
+	assertEqual(t, false, reg.Command("add").Args["name"].optional)
+	assertEqual(t, true, reg.Command("rm").Args["name"].optional)
+	assertEqual(t, true, reg.Command("rm").Flags["all"] != nil)
+	assertEqual(t, true, reg.Command("add").Flags["all"] == nil)
+
+	tests = []struct {
+		args []string
+		err  bool
+	}{
+		{[]string{"add", "x"}, false},
+		{[]string{"add", "x", "-f"}, false},
+		{[]string{"rm", "x"}, false},
+		{[]string{"rm", "--all"}, false},
+		{[]string{"rm", "x", "--all"}, true},
+		{[]string{"add"}, true},
+		{[]string{"list"}, false},
+		{[]string{"list", "x"}, true},
+	}
+	for _, test := range tests {
+		cmd, err := reg.Parse(test.args)
+		if test.err {
+			assertError(t, err, "%v", test.args)
+			continue
+		}
+		assertNoError(t, err, "%v", test.args)
+		assertEqual(t, test.args[0], cmd.Command.Name)
+	}
+
+	// invalid usage texts
+	invalid := []string{
+		"no usage here",
+		"Usage: app (<a>",
+		"Usage: app <a>]",
+		"Usage: app ship new <name>",
+		"Usage: app (add|<name>)",
+		"Usage: app (add|rm <name>)",
+		"Usage: app info\n  other info",
+		"Usage: app <a>... <b>...",
+		"Usage: app <a>... [<b>]",
+		"Usage: app [options]\nOptions:\n  -v  Verbose.\n  -v  Very.",
+		"Usage: app --verbose -v\nOptions:\n  -v, --verbose  Verbose.\n  --verbose=<level>  Level.",
+	}
+	for _, usage := range invalid {
+		_, err := NewRegistryFromUsage(usage)
+		assertError(t, err, "%q", usage)
+	}
+}
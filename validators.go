@@ -0,0 +1,314 @@
+package clapper
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+)
+
+// constraint names of `BadArgument` errors
+const (
+	ConstraintRange   = "range"
+	ConstraintLength  = "length"
+	ConstraintPattern = "pattern"
+	ConstraintCount   = "count"
+)
+
+// bounds of a length or a count; a negative max means no maximum
+type bounds struct {
+	min, max int
+}
+
+// WithRange restricts the values of a numeric or `time.Duration` argument to
+// the range from `min` to `max` (inclusive). A `nil` bound is not checked.
+// The bounds are converted to the type of the argument, so `WithRange(1, 10)`
+// works for any integer type; a bound which doesn't convert exactly, like 1000
+// for an `int8` or 0.5 for an `int`, is a mistake (see `Registry.Validate`).
+func (a *Arg) WithRange(min interface{}, max interface{}) *Arg {
+	return a.update(func() { a.min, a.max = min, max })
+}
+
+// WithLength restricts the length (in characters) of the values of a string
+// argument to the range from `min` to `max` (inclusive). A negative `max`
+// means no maximum.
+func (a *Arg) WithLength(min int, max int) *Arg {
+	return a.update(func() { a.length = &bounds{min, max} })
+}
+
+// WithPattern restricts the values of a string argument to the values
+// matching the regular expression `pattern` as a whole. If `pattern` doesn't
+// compile, the values are not restricted, and the mistake is recorded, so
+// `Registry.Validate` returns it.
+func (a *Arg) WithPattern(pattern string) *Arg {
+	compiled, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	return a.update(func() {
+		if err != nil {
+			a.record(fmt.Sprintf("illegal pattern %s, %v", pattern, err))
+			return
+		}
+		a.pattern, a.patternSource = compiled, pattern
+	})
+}
+
+// WithCount restricts the number of values of a variadic argument or a list
+// flag to the range from `min` to `max` (inclusive). A negative `max` means
+// no maximum.
+func (a *Arg) WithCount(min int, max int) *Arg {
+	return a.update(func() { a.count = &bounds{min, max} })
+}
+
+// minCount returns the minimum number of values of the count constraint of the argument.
+func (a *Arg) minCount() int {
+	if a.count == nil || a.count.min < 0 {
+		return 0
+	}
+	return a.count.min
+}
+
+// checkConstraintTypes checks that the constraints of the argument apply to
+// its type. `isList` tells if the argument takes a list of values.
+func (a *Arg) checkConstraintTypes(isList bool) error {
+	p := a.elemType()
+	if p == nil {
+		return nil
+	}
+
+	for _, bound := range []interface{}{a.min, a.max} {
+		if bound == nil {
+			continue
+		}
+		if !isNumber(p) || p.Implements(valueType) {
+			return fmt.Errorf("range of a non-numeric argument of type %v", p)
+		}
+		b := reflect.ValueOf(bound)
+		if !isNumber(b.Type()) || !b.Type().ConvertibleTo(p) {
+			return fmt.Errorf("range bound %v is not a number", bound)
+		}
+		if isUnsigned(p) && (b.CanInt() && b.Int() < 0 || b.CanFloat() && b.Float() < 0) {
+			return fmt.Errorf("range bound %v is negative for unsigned argument", bound)
+		}
+
+		// the bound must be converted exactly, e.g. 1000 overflows an int8
+		// and 0.5 is truncated by an int
+		if b.Convert(p).Convert(b.Type()).Interface() != b.Interface() {
+			return fmt.Errorf("range bound %v is not a value of type %v", bound, p)
+		}
+	}
+
+	if (a.length != nil || a.pattern != nil) && p.Kind() != reflect.String {
+		return fmt.Errorf("length or pattern of a non-string argument of type %v", p)
+	}
+	if a.count != nil && !isList {
+		return fmt.Errorf("count of an argument that doesn't take a list of values")
+	}
+	return nil
+}
+
+// elemType returns the type of a single value of the argument.
+func (a *Arg) elemType() reflect.Type {
+	p := reflect.TypeOf(a.defaultValue)
+	if p != nil && (isList(p) || p.Kind() == reflect.Map) {
+		p = p.Elem()
+	}
+	return p
+}
+
+// validateConstraints checks the value of the argument against its
+// constraints. `pos` is the position of the token that provided the value.
+func validateConstraints(a *Arg, pos int) error {
+	if a.value == nil {
+		return nil
+	}
+
+	// the constraints are checked by `Registry.Freeze` too, but a registry
+	// doesn't need to be frozen
+	pv := reflect.ValueOf(a.value)
+	if err := a.checkConstraintTypes(isList(pv.Type())); err != nil {
+		return fmt.Errorf("%s: %v", a.Name, err)
+	}
+
+	if pv.Kind() == reflect.Map {
+		iter := pv.MapRange()
+		for iter.Next() {
+			if err := validateElementConstraints(a, iter.Value().Interface(), pos); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !isList(pv.Type()) {
+		return validateElementConstraints(a, a.value, pos)
+	}
+
+	for i := 0; i < pv.Len(); i++ {
+		if err := validateElementConstraints(a, pv.Index(i).Interface(), pos); err != nil {
+			return err
+		}
+	}
+	if a.count != nil && a.count.max >= 0 && pv.Len() > a.count.max {
+		return constraintError(a, ConstraintCount, pos, fmt.Sprintf("takes at most %d values, got %d", a.count.max, pv.Len()))
+	}
+	return nil
+}
+
+// validateCount checks that the argument got at least the minimum number of
+// values of its count constraint.
+func validateCount(a *Arg) error {
+	if a.count == nil {
+		return nil
+	}
+
+	n := 0
+	if a.value != nil {
+		n = reflect.ValueOf(a.value).Len()
+	}
+	if n >= a.count.min {
+		return nil
+	}
+
+	pos := -1
+	if len(a.positions) > 0 {
+		pos = a.positions[len(a.positions)-1]
+	}
+	return constraintError(a, ConstraintCount, pos, fmt.Sprintf("takes at least %d values, got %d", a.count.min, n))
+}
+
+// validateElementConstraints checks a single value of the argument against its constraints.
+func validateElementConstraints(a *Arg, value interface{}, pos int) error {
+	v := reflect.ValueOf(value)
+
+	if a.min != nil && compareNumbers(v, reflect.ValueOf(a.min)) < 0 {
+		return constraintError(a, ConstraintRange, pos, fmt.Sprintf("value %v out of range, must be at least %v", value, reflect.ValueOf(a.min).Convert(v.Type())))
+	}
+	if a.max != nil && compareNumbers(v, reflect.ValueOf(a.max)) > 0 {
+		return constraintError(a, ConstraintRange, pos, fmt.Sprintf("value %v out of range, must be at most %v", value, reflect.ValueOf(a.max).Convert(v.Type())))
+	}
+
+	if v.Kind() != reflect.String {
+		return nil
+	}
+	if a.length != nil {
+		n := utf8.RuneCountInString(v.String())
+		if n < a.length.min {
+			return constraintError(a, ConstraintLength, pos, fmt.Sprintf("value %q too short, must have at least %d characters", value, a.length.min))
+		}
+		if a.length.max >= 0 && n > a.length.max {
+			return constraintError(a, ConstraintLength, pos, fmt.Sprintf("value %q too long, must have at most %d characters", value, a.length.max))
+		}
+	}
+	if a.pattern != nil && !a.pattern.MatchString(v.String()) {
+		return constraintError(a, ConstraintPattern, pos, fmt.Sprintf("value %q must match %s", value, a.patternSource))
+	}
+	return nil
+}
+
+// constraintError returns the error of a value violating a constraint of the argument.
+func constraintError(a *Arg, constraint string, pos int, message string) BadArgument {
+	return BadArgument{Arg: a, Message: message, Constraint: constraint, Position: pos}
+}
+
+// compareNumbers compares the number `v` with the number `bound` converted to
+// the type of `v`, and returns -1, 0 or 1.
+func compareNumbers(v reflect.Value, bound reflect.Value) int {
+	b := bound.Convert(v.Type())
+	switch {
+	case v.CanInt():
+		return compare(v.Int() < b.Int(), v.Int() > b.Int())
+	case v.CanUint():
+		return compare(v.Uint() < b.Uint(), v.Uint() > b.Uint())
+	default:
+		return compare(v.Float() < b.Float(), v.Float() > b.Float())
+	}
+}
+
+func compare(less bool, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// isNumber tells if the type `p` is an integer or a floating-point type.
+func isNumber(p reflect.Type) bool {
+	switch p.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isUnsigned tells if the type `p` is an unsigned integer type.
+func isUnsigned(p reflect.Type) bool {
+	switch p.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// WithValidator adds a custom validator of the argument. After parsing, the
+// validators of a provided argument are called in the order they were added,
+// with the value of the argument (e.g. an `int` or a `[]string`). An error of
+// a validator is returned by `Parse` as a `BadArgument` error wrapping it.
+func (a *Arg) WithValidator(fn func(value interface{}) error) *Arg {
+	return a.update(func() { a.validators = append(a.validators, fn) })
+}
+
+// AddValidator adds a custom validator of the command, e.g. to check values
+// depending on each other, like `--end` after `--start`. After parsing, and
+// after the validators of the arguments and flags, the validators of the
+// command are called in the order they were added. An error of a validator is
+// returned by `Parse` as a `BadCommand` error wrapping it.
+// If the registry of the command is frozen, it returns `ErrFrozen`.
+func (commandConfig *CommandConfig) AddValidator(fn func(*ParseResult) error) error {
+	err := commandConfig.lock()
+	defer commandConfig.unlock()
+	if err != nil {
+		return err
+	}
+
+	commandConfig.validators = append(commandConfig.validators, fn)
+	return nil
+}
+
+// runValidators calls the validators of the provided arguments, of the
+// provided flags (named `flagNames`, in that order), and of the command.
+func runValidators(result *ParseResult, flagNames []string) error {
+	for _, argName := range result.ArgNames {
+		if err := runArgValidators(result.Args[argName]); err != nil {
+			return err
+		}
+	}
+	for _, name := range flagNames {
+		if err := runArgValidators(&result.Flags[name].Arg); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range result.validators {
+		if err := fn(result); err != nil {
+			return BadCommand{Command: result.Command, Err: err}
+		}
+	}
+	return nil
+}
+
+// runArgValidators calls the validators of the argument, if it was provided.
+func runArgValidators(a *Arg) error {
+	if !a.IsSet() || a.value == nil {
+		return nil
+	}
+
+	for _, fn := range a.validators {
+		if err := fn(a.value); err != nil {
+			return BadArgument{Arg: a, Message: err.Error(), Position: a.positions[len(a.positions)-1], Err: err}
+		}
+	}
+	return nil
+}
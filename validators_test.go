@@ -0,0 +1,305 @@
+package clapper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// test value constraints
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		args       []string
+		constraint string
+		position   int
+	}{
+		{[]string{"--port", "8080"}, "", 0},
+		{[]string{"--port", "80"}, ConstraintRange, 1},
+		{[]string{"--port=65535"}, "", 0},
+		{[]string{"-v", "--port=70000"}, "", 0},
+		{[]string{"--timeout", "1s"}, ConstraintRange, 1},
+		{[]string{"--timeout", "2h"}, "", 0},
+		{[]string{"--timeout", "2d"}, ConstraintRange, 1},
+		{[]string{"--ratio", "0.5"}, "", 0},
+		{[]string{"--ratio", "1.5"}, ConstraintRange, 1},
+		{[]string{"--name", "ab"}, ConstraintLength, 1},
+		{[]string{"--name", "ünï"}, "", 0},
+		{[]string{"--name", "abcdefghi"}, ConstraintLength, 1},
+		{[]string{"--tag", "v1.2.3"}, "", 0},
+		{[]string{"--tag", "v1.2.3-rc1"}, ConstraintPattern, 1},
+		{[]string{"--tag", "xv1.2.3"}, ConstraintPattern, 1},
+		{[]string{"--tags", "a,b,c"}, "", 0},
+		{[]string{"--tags", "a,bb"}, ConstraintLength, 1},
+		{[]string{"--tags", "a,b", "--tags", "c,d"}, ConstraintCount, 3},
+		{[]string{"--limit", "cpu=2"}, "", 0},
+		{[]string{"--limit", "cpu=2", "--limit", "mem=0"}, ConstraintRange, 3},
+		{[]string{"x", "y", "z", "w"}, ConstraintCount, 3},
+		{[]string{"x", "yy", "z"}, ConstraintPattern, 1},
+		{nil, ConstraintCount, -1},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		root.AddArg("files...", "").WithCount(1, 3).WithPattern(`[a-z]`)
+		root.AddFlag("verbose", "v", false)
+		port, _ := root.AddFlag("port", "p", 0)
+		port.WithRange(1024, nil)
+		timeout, _ := root.AddFlag("timeout", "", time.Duration(0))
+		timeout.WithRange(time.Minute, 24*time.Hour)
+		ratio, _ := root.AddFlag("ratio", "", 0.0)
+		ratio.WithRange(0, 1)
+		name, _ := root.AddFlag("name", "", "")
+		name.WithLength(3, 8)
+		tag, _ := root.AddFlag("tag", "", "")
+		tag.WithPattern(`v\d+\.\d+\.\d+`)
+		tags, _ := root.AddListFlag("tags", "", "", ",")
+		tags.WithLength(1, 1).WithCount(0, 3)
+		limit, _ := root.AddFlag("limit", "", map[string]uint{})
+		limit.WithRange(1, 64)
+		assertNoError(t, reg.Freeze())
+
+		// flags are tested with a file argument first
+		args := test.args
+		if args != nil && args[0] != "x" {
+			args = append([]string{"a"}, args...)
+			if test.position > 0 {
+				test.position++
+			}
+		}
+		_, err := reg.Parse(args)
+		if test.constraint == "" {
+			assertNoError(t, err, "%v", args)
+			continue
+		}
+		assertError(t, err, "%v", args)
+		if e, ok := err.(BadArgument); ok {
+			assertEqual(t, test.constraint, e.Constraint, " %v (constraint)", args)
+			assertEqual(t, test.position, e.Position, " %v (position)", args)
+		} else {
+			t.Errorf("expected a BadArgument error, got %#v (%v)", err, args)
+		}
+	}
+}
+
+// test that constraints must apply to the types of the arguments and flags
+func TestConstraintTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*CommandConfig)
+	}{
+		{"range of a string", func(c *CommandConfig) { c.AddArg("name", "").WithRange(1, 2) }},
+		{"string range bound", func(c *CommandConfig) { c.AddArg("size", 0).WithRange("1", nil) }},
+		{"negative unsigned bound", func(c *CommandConfig) { c.AddArg("size", uint(0)).WithRange(-1, nil) }},
+		{"overflowing bound", func(c *CommandConfig) { c.AddArg("level", int8(0)).WithRange(0, 1000) }},
+		{"overflowing unsigned bound", func(c *CommandConfig) { c.AddArg("level", uint8(0)).WithRange(nil, 256) }},
+		{"fractional bound", func(c *CommandConfig) { c.AddArg("size", 0).WithRange(0.5, 10) }},
+		{"length of a number", func(c *CommandConfig) { c.AddArg("size", 0).WithLength(1, 2) }},
+		{"pattern of a number", func(c *CommandConfig) { c.AddArg("size", 0).WithPattern(`\d`) }},
+		{"illegal pattern", func(c *CommandConfig) { c.AddArg("name", "").WithPattern(`[a-z`) }},
+		{"count of a single value", func(c *CommandConfig) { c.AddArg("name", "").WithCount(1, 2) }},
+		{"count of a flag", func(c *CommandConfig) {
+			f, _ := c.AddFlag("name", "", "")
+			f.WithCount(1, 2)
+		}},
+	}
+
+	for _, test := range tests {
+		reg := NewRegistry()
+		root, _ := reg.Register("")
+		test.setup(root)
+		assertError(t, reg.Freeze(), test.name)
+	}
+
+	// the constraints are checked when parsing without freezing too
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	root.AddArg("name", "").WithRange(1, 2)
+	_, err := reg.Parse([]string{"a"})
+	assertError(t, err)
+
+	// an illegal pattern is a mistake, and doesn't restrict the values
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	tag, _ := root.AddFlag("tag", "", "")
+	tag.WithPattern(`v(\d+`)
+	assertEqual(t, SchemaErrors{{Command: "", Flag: "tag", Message: "illegal pattern v(\\d+, error parsing regexp: missing closing ): `^(?:v(\\d+)$`"}}, reg.Validate())
+	cmd, err := reg.Parse([]string{"--tag", "x"})
+	assertNoError(t, err)
+	assertEqual(t, "x", cmd.Flags["tag"].AsString())
+
+	// an int8 range from 0 to 1000 is a mistake, rather than a maximum of -24
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	level, _ := root.AddFlag("level", "", int8(0))
+	level.WithRange(0, 1000)
+	assertError(t, reg.Validate())
+	_, err = reg.Parse([]string{"--level", "5"})
+	assertError(t, err)
+	if _, ok := err.(BadArgument); ok {
+		t.Errorf("expected a mistake of the range, got %v", err)
+	}
+
+	// exact bounds of other types are accepted
+	reg = NewRegistry()
+	root, _ = reg.Register("")
+	level, _ = root.AddFlag("level", "", int8(0))
+	level.WithRange(-128, 127.0)
+	ratio, _ := root.AddFlag("ratio", "", 0.0)
+	ratio.WithRange(0, 1)
+	size, _ := root.AddFlag("size", "", uint8(0))
+	size.WithRange(uint64(1), int64(255))
+	assertNoError(t, reg.Validate())
+	cmd, err = reg.Parse([]string{"--level", "-128", "--ratio", "0.5", "--size", "255"})
+	assertNoError(t, err)
+	assertEqual(t, int8(-128), cmd.Flags["level"].value)
+}
+
+// test custom validators
+func TestValidators(t *testing.T) {
+	errOdd := errors.New("must be even")
+	errOrder := errors.New("--end must be after --start")
+
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	var calls []string
+	root.AddArg("files...", "").WithValidator(func(value interface{}) error {
+		calls = append(calls, "files")
+		for _, file := range value.([]string) {
+			if !strings.HasSuffix(file, ".json") {
+				return fmt.Errorf("file %s is not a JSON file", file)
+			}
+		}
+		return nil
+	})
+	workers, _ := root.AddFlag("workers", "w", 1)
+	workers.WithValidator(func(value interface{}) error {
+		calls = append(calls, "workers")
+		if value.(int)%2 != 0 {
+			return errOdd
+		}
+		return nil
+	})
+	root.AddFlag("start", "", time.Time{})
+	root.AddFlag("end", "", time.Time{})
+	assertNoError(t, root.AddValidator(func(result *ParseResult) error {
+		calls = append(calls, "command")
+		start, end := result.Flags["start"], result.Flags["end"]
+		if start.IsSet() && end.IsSet() && !end.AsTime().After(start.AsTime()) {
+			return errOrder
+		}
+		return nil
+	}))
+
+	_, err := reg.Parse([]string{"a.json", "-w", "4", "--start", "2026-10-01", "--end", "2026-10-02"})
+	assertNoError(t, err)
+	assertEqual(t, []string{"files", "workers", "command"}, calls)
+
+	// validators of arguments and flags which are not provided are not called
+	calls = nil
+	_, err = reg.Parse([]string{})
+	assertNoError(t, err)
+	assertEqual(t, []string{"command"}, calls)
+
+	_, err = reg.Parse([]string{"a.json", "b.yaml"})
+	assertError(t, err)
+	if e, ok := err.(BadArgument); ok {
+		assertEqual(t, "files", e.Arg.Name)
+		assertEqual(t, 1, e.Position)
+		assertEqual(t, "files file b.yaml is not a JSON file", e.Error())
+	} else {
+		t.Errorf("expected a BadArgument error, got %#v", err)
+	}
+
+	_, err = reg.Parse([]string{"-w", "2", "--workers", "3"})
+	assertError(t, err)
+	assertEqual(t, true, errors.Is(err, errOdd))
+	var badArgument BadArgument
+	assertEqual(t, true, errors.As(err, &badArgument))
+	assertEqual(t, 2, badArgument.Position)
+
+	_, err = reg.Parse([]string{"--start", "2026-10-02", "--end", "2026-10-01"})
+	assertError(t, err)
+	assertEqual(t, true, errors.Is(err, errOrder))
+	if e, ok := err.(BadCommand); ok {
+		assertEqual(t, root, e.Command)
+		assertEqual(t, "invalid arguments: --end must be after --start", e.Error())
+	} else {
+		t.Errorf("expected a BadCommand error, got %#v", err)
+	}
+
+	// no validators can be added to a frozen registry
+	assertNoError(t, reg.Freeze())
+	assertEqual(t, ErrFrozen, root.AddValidator(func(*ParseResult) error { return nil }))
+}
+
+// test that validators can use the registry, which is unlocked when they are called
+func TestValidatorsUseRegistry(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	workers, _ := root.AddFlag("workers", "w", 1)
+	workers.WithValidator(func(interface{}) error {
+		workers.WithRange(1, 8)
+		return nil
+	})
+	root.AddValidator(func(res *ParseResult) error {
+		if reg.Command("") != res.Command {
+			return errors.New("unexpected command")
+		}
+		return res.Command.AddValidator(func(*ParseResult) error { return nil })
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := reg.Parse([]string{"-w", "2"})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assertNoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the validators are blocked by the registry")
+	}
+	assertEqual(t, 2, len(root.validators))
+}
+
+// test that the arguments and flags of a frozen registry can't be changed
+// while parsing (run with `go test -race`)
+func TestFrozenArgs(t *testing.T) {
+	reg := NewRegistry()
+	root, _ := reg.Register("")
+	workers, _ := root.AddFlag("workers", "w", 1)
+	workers.WithRange(1, 8)
+	files := root.AddArg("files...", "")
+	assertNoError(t, reg.Freeze())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd, err := reg.Parse([]string{"-w", "8", "a", "b"})
+			assertNoError(t, err)
+			assertEqual(t, 8, cmd.Flags["workers"].value)
+		}()
+	}
+
+	workers.WithRange(1, 4).WithValidator(func(interface{}) error { return errors.New("no") })
+	files.WithCount(3, -1).WithLength(2, -1).WithPattern(`.*\.json`).Optional()
+	files.WithTimeLayouts("15:04").WithLocation(time.UTC).WithSchemes("https").WithPathChecks(PathExists)
+	wg.Wait()
+
+	// the changes are mistakes, and have no effect
+	_, err := reg.Parse([]string{"-w", "8", "a", "b"})
+	assertNoError(t, err)
+	err = reg.Validate()
+	assertError(t, err)
+	errs, _ := err.(SchemaErrors)
+	assertEqual(t, 10, len(errs))
+	assertEqual(t, SchemaError{Command: "", Flag: "workers", Message: ErrFrozen.Error()}, errs[0])
+	assertEqual(t, SchemaError{Command: "", Arg: "files", Message: ErrFrozen.Error()}, errs[9])
+}